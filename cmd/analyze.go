@@ -2,10 +2,10 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 
-	"github.com/schrockwell/sse/internal/keyfile"
 	"github.com/schrockwell/sse/internal/secrets"
 	"github.com/spf13/cobra"
 )
@@ -17,22 +17,58 @@ var analyzeCmd = &cobra.Command{
 - Keys that are missing from some environments
 - Values that are identical across multiple environments
 
+Inherited values are taken into account: a key set in [shared] or in an
+environment that others inherit from is not reported as missing, and is
+only reported as equal when another section sets the same value again.
+
 This helps identify configuration inconsistencies and potential copy-paste errors.`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		identity, err := keyfile.LoadIdentity()
+		key, err := loadKey()
 		if err != nil {
 			return err
 		}
 
-		f, err := secrets.Load(secrets.DefaultFile)
+		f, err := secrets.Load(secretsPath())
 		if err != nil {
 			return err
 		}
 
+		// Decrypt all environments, leaving out those we are not a recipient of
+		decrypted, skipped, err := f.DecryptAll(key.Identities...)
+		if err != nil {
+			return err
+		}
+		for _, envName := range skipped {
+			fmt.Fprintf(os.Stderr, "Skipped %s (not a recipient)\n", envName)
+		}
+
+		// Resolve each environment with the values it inherits, remembering which
+		// section every value is defined in
+		isSkipped := make(map[string]bool)
+		for _, envName := range skipped {
+			isSkipped[envName] = true
+		}
+		resolved := make(map[string]map[string]string)
+		sources := make(map[string]map[string]string)
+		for envName := range decrypted {
+			if secrets.IsBase(envName) {
+				continue
+			}
+			layers, err := f.Layers(envName)
+			if err != nil {
+				return err
+			}
+			if layer := firstSkipped(layers, isSkipped); layer != "" {
+				fmt.Fprintf(os.Stderr, "Skipped %s (inherits from %s, not a recipient)\n", envName, layer)
+				continue
+			}
+			resolved[envName], sources[envName] = secrets.Merge(layers, decrypted)
+		}
+
 		// Collect all environment names
-		envNames := make([]string, 0, len(f.Environments))
-		for name := range f.Environments {
+		envNames := make([]string, 0, len(resolved))
+		for name := range resolved {
 			envNames = append(envNames, name)
 		}
 		sort.Strings(envNames)
@@ -42,20 +78,9 @@ This helps identify configuration inconsistencies and potential copy-paste error
 			return nil
 		}
 
-		// Decrypt all environments
-		decrypted := make(map[string]map[string]string)
-		for _, envName := range envNames {
-			env := f.Environments[envName]
-			dec, err := secrets.DecryptEnvironment(env, identity)
-			if err != nil {
-				return fmt.Errorf("failed to decrypt %s: %w", envName, err)
-			}
-			decrypted[envName] = dec
-		}
-
 		// Collect all keys across all environments
 		allKeys := make(map[string]bool)
-		for _, env := range decrypted {
+		for _, env := range resolved {
 			for key := range env {
 				allKeys[key] = true
 			}
@@ -72,7 +97,7 @@ This helps identify configuration inconsistencies and potential copy-paste error
 		for _, key := range sortedKeys {
 			var missingFrom []string
 			for _, envName := range envNames {
-				if _, exists := decrypted[envName][key]; !exists {
+				if _, exists := resolved[envName][key]; !exists {
 					missingFrom = append(missingFrom, envName)
 				}
 			}
@@ -81,32 +106,43 @@ This helps identify configuration inconsistencies and potential copy-paste error
 			}
 		}
 
-		// Check for equal values and uniquely defined keys
+		// Check for equal values and uniquely defined keys. An inherited value is defined
+		// once, so only values defined separately in more than one section are equal.
 		var equalIssues []string
 		var uniqueKeys []string
 		for _, key := range sortedKeys {
-			// Group environments by value
-			valueToEnvs := make(map[string][]string)
+			// Group the sections that define each value
+			valueToSources := make(map[string][]string)
+			distinct := make(map[string]bool)
 			presentInAll := true
 			for _, envName := range envNames {
-				if value, exists := decrypted[envName][key]; exists {
-					valueToEnvs[value] = append(valueToEnvs[value], envName)
-				} else {
+				value, exists := resolved[envName][key]
+				if !exists {
 					presentInAll = false
+					continue
+				}
+				distinct[value] = true
+				source := sources[envName][key]
+				if !contains(valueToSources[value], source) {
+					valueToSources[value] = append(valueToSources[value], source)
 				}
 			}
 
-			// Check if any value appears in multiple environments
-			hasEqual := false
-			for _, envs := range valueToEnvs {
-				if len(envs) > 1 {
-					equalIssues = append(equalIssues, fmt.Sprintf("%s is equal in: %s", key, strings.Join(envs, ", ")))
-					hasEqual = true
+			// Check if any value is defined in multiple sections
+			values := make([]string, 0, len(valueToSources))
+			for value := range valueToSources {
+				values = append(values, value)
+			}
+			sort.Strings(values)
+			for _, value := range values {
+				if defined := valueToSources[value]; len(defined) > 1 {
+					sort.Strings(defined)
+					equalIssues = append(equalIssues, fmt.Sprintf("%s is equal in: %s", key, strings.Join(defined, ", ")))
 				}
 			}
 
 			// Key is uniquely defined if present in all environments with all different values
-			if presentInAll && !hasEqual {
+			if presentInAll && len(distinct) == len(envNames) {
 				uniqueKeys = append(uniqueKeys, key)
 			}
 		}
@@ -147,6 +183,25 @@ This helps identify configuration inconsistencies and potential copy-paste error
 	},
 }
 
+// firstSkipped returns the first of layers that could not be decrypted, or "".
+func firstSkipped(layers []string, isSkipped map[string]bool) string {
+	for _, layer := range layers {
+		if isSkipped[layer] {
+			return layer
+		}
+	}
+	return ""
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
 func init() {
 	rootCmd.AddCommand(analyzeCmd)
 }
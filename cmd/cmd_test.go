@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// newProject runs sse init in a new temporary directory and makes it the working directory.
+func newProject(t *testing.T) string {
+	t.Helper()
+	for _, name := range []string{"SSE_FILE", "SSE_KEY_FILE", "SSE_ENV", "SSE_MASTER_KEY", "SSE_STRICT"} {
+		t.Setenv(name, "")
+	}
+
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	runSSE(t, "init")
+	return dir
+}
+
+// runSSE runs sse with the given arguments and returns what it printed to stdout.
+// It fails the test if the command fails.
+func runSSE(t *testing.T, args ...string) string {
+	t.Helper()
+	out, err := trySSE(t, args...)
+	if err != nil {
+		t.Fatalf("sse %s: %v", strings.Join(args, " "), err)
+	}
+	return out
+}
+
+// trySSE runs sse with the given arguments and returns what it printed to stdout.
+func trySSE(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	resetFlags(rootCmd)
+	rootDir = ""
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+
+	rootCmd.SetArgs(args)
+	rootCmd.SilenceUsage = true
+	err = rootCmd.Execute()
+
+	os.Stdout = stdout
+	w.Close()
+	return <-output, err
+}
+
+// resetFlags puts every flag of cmd and its subcommands back to its default, as cobra keeps
+// the values between runs.
+func resetFlags(cmd *cobra.Command) {
+	reset := func(f *pflag.Flag) {
+		if slice, ok := f.Value.(pflag.SliceValue); ok {
+			slice.Replace(nil)
+		} else {
+			f.Value.Set(f.DefValue)
+		}
+		f.Changed = false
+	}
+	cmd.PersistentFlags().VisitAll(reset)
+	cmd.Flags().VisitAll(reset)
+	for _, sub := range cmd.Commands() {
+		resetFlags(sub)
+	}
+}
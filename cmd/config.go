@@ -0,0 +1,175 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/schrockwell/sse/internal/keyfile"
+	"github.com/schrockwell/sse/internal/project"
+	"github.com/schrockwell/sse/internal/recipients"
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+const defaultSource = "default"
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Show and change project settings in .sse.toml",
+	Long: `Show and change the per-project defaults in .sse.toml.
+
+.sse.toml lives at the project root and is meant to be committed. Paths in
+it are relative to the project root.
+
+Settings:
+  env           default environment (development)
+  file          path to env.toml (env.toml)
+  key           path to master.key (master.key)
+  recipients    path to the recipients file (.sse-recipients next to env.toml)
+  editor        editor for "sse edit" (VS Code or vim)
+  environments  comma-separated environments "sse init" creates (development,production)
+
+Each setting is taken from the first of:
+  1. its flag (--env, --file, --key)
+  2. its environment variable (SSE_ENV, SSE_FILE, SSE_KEY_FILE, $EDITOR or $VISUAL)
+  3. .sse.toml
+  4. the built-in default
+
+Examples:
+  sse config list                   # show effective settings and their sources
+  sse config get env                # print the default environment
+  sse config set env staging        # make staging the default environment
+  sse config set environments development,staging,production`,
+}
+
+var configListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List effective settings and where they come from",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, name := range project.Settings {
+			value, source := setting(name)
+			if value == "" {
+				value = "-"
+			}
+			fmt.Fprintf(w, "%s\t%s\t(%s)\n", name, value, source)
+		}
+		return w.Flush()
+	},
+}
+
+var configGetCmd = &cobra.Command{
+	Use:   "get NAME",
+	Short: "Print the effective value of a setting",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// Validate the name
+		if _, err := projectConfig.Get(args[0]); err != nil {
+			return err
+		}
+		value, _ := setting(args[0])
+		fmt.Println(value)
+		return nil
+	},
+}
+
+var configSetCmd = &cobra.Command{
+	Use:   "set NAME VALUE",
+	Short: "Change a setting in .sse.toml",
+	Long: `Change a setting in .sse.toml at the project root, creating the file if needed.
+An empty VALUE removes the setting.`,
+	Args: cobra.ExactArgs(2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := projectConfig.Set(args[0], args[1]); err != nil {
+			return err
+		}
+
+		path := filepath.Join(projectRoot(), project.ConfigFile)
+		if err := projectConfig.Save(path); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Set %s in %s\n", args[0], path)
+		return nil
+	},
+}
+
+// setting returns the effective value of a .sse.toml setting and where it came from:
+// its flag, its environment variable, .sse.toml or the built-in default, in that order.
+func setting(name string) (string, string) {
+	switch name {
+	case "env":
+		return lookup(environment, "--env", secrets.EnvironmentEnvVar, projectConfig.Env, secrets.DefaultEnvironment)
+	case "file":
+		return rootRelative(lookup(secretsFile, "--file", secrets.FileEnvVar, projectConfig.File, secrets.DefaultFile))
+	case "key":
+		return rootRelative(lookup(keyFile, "--key", keyfile.KeyFileEnvVar, projectConfig.Key, keyfile.DefaultKeyFile))
+	case "recipients":
+		if projectConfig.Recipients != "" {
+			return rootRelative(projectConfig.Recipients, project.ConfigFile)
+		}
+		return recipients.PathFor(secretsPath()), defaultSource
+	case "editor":
+		for _, envVar := range []string{"EDITOR", "VISUAL"} {
+			if editor := os.Getenv(envVar); editor != "" {
+				return editor, "$" + envVar
+			}
+		}
+		if projectConfig.Editor != "" {
+			return projectConfig.Editor, project.ConfigFile
+		}
+		return "", defaultSource
+	case "environments":
+		return strings.Join(projectEnvironments(), ","), environmentsSource()
+	}
+	return "", ""
+}
+
+// lookup returns the first value that is set, along with its source.
+func lookup(flagValue, flagName, envVar, configValue, defaultValue string) (string, string) {
+	if flagValue != "" {
+		return flagValue, flagName
+	}
+	if value := os.Getenv(envVar); value != "" {
+		return value, "$" + envVar
+	}
+	if configValue != "" {
+		return configValue, project.ConfigFile
+	}
+	return defaultValue, defaultSource
+}
+
+// rootRelative resolves paths from .sse.toml and the defaults against the project root.
+// Paths from flags and environment variables stay relative to the working directory.
+func rootRelative(path, source string) (string, string) {
+	if (source == project.ConfigFile || source == defaultSource) && !filepath.IsAbs(path) {
+		path = filepath.Join(projectRoot(), path)
+	}
+	return path, source
+}
+
+// projectEnvironments returns the environments that sse init creates.
+func projectEnvironments() []string {
+	if len(projectConfig.Environments) > 0 {
+		return projectConfig.Environments
+	}
+	return secrets.DefaultEnvironments
+}
+
+func environmentsSource() string {
+	if len(projectConfig.Environments) > 0 {
+		return project.ConfigFile
+	}
+	return defaultSource
+}
+
+func init() {
+	configCmd.AddCommand(configListCmd)
+	configCmd.AddCommand(configGetCmd)
+	configCmd.AddCommand(configSetCmd)
+	rootCmd.AddCommand(configCmd)
+}
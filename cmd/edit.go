@@ -5,9 +5,8 @@ import (
 	"os"
 	"os/exec"
 	"sort"
+	"strings"
 
-	"github.com/BurntSushi/toml"
-	"github.com/schrockwell/sse/internal/keyfile"
 	"github.com/schrockwell/sse/internal/secrets"
 	"github.com/spf13/cobra"
 )
@@ -18,31 +17,33 @@ var editCmd = &cobra.Command{
 	Long: `Decrypt all values in env.toml, open in your editor,
 then re-encrypt when the editor closes.
 
-Uses $EDITOR, $VISUAL, VS Code, or vim (in that order).
+Comments and the order of keys are kept, and any comments added or
+changed in the editor are saved back to env.toml.
+
+Uses $EDITOR, $VISUAL, the editor in .sse.toml, VS Code, or vim (in that order).
 
 Examples:
   sse edit`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		identity, err := keyfile.LoadIdentity()
+		key, err := loadKey()
+		if err != nil {
+			return err
+		}
+		recipientList, err := loadRecipientList()
 		if err != nil {
 			return err
 		}
-		recipient := identity.Recipient()
 
-		f, err := secrets.Load(secrets.DefaultFile)
+		f, err := secrets.Load(secretsPath())
 		if err != nil {
 			return err
 		}
 
-		// Decrypt all environments
-		decryptedEnvs := make(map[string]map[string]string)
-		for envName, env := range f.Environments {
-			decrypted, err := secrets.DecryptEnvironment(env, identity)
-			if err != nil {
-				return fmt.Errorf("failed to decrypt %s: %w", envName, err)
-			}
-			decryptedEnvs[envName] = decrypted
+		// Decrypt all environments, leaving out those we are not a recipient of
+		decryptedEnvs, skipped, err := f.DecryptAll(key.Identities...)
+		if err != nil {
+			return err
 		}
 
 		// Create temp file with decrypted TOML
@@ -53,38 +54,25 @@ Examples:
 		tmpPath := tmpFile.Name()
 		defer os.Remove(tmpPath)
 
-		// Write decrypted TOML
-		envNames := make([]string, 0, len(decryptedEnvs))
-		for name := range decryptedEnvs {
-			envNames = append(envNames, name)
+		// Note sections that are left out, so they aren't mistaken for deleted
+		var notes strings.Builder
+		for _, envName := range skipped {
+			fmt.Fprintf(&notes, "# [%s] is not shown (not a recipient) and will be kept as is\n", envName)
 		}
-		sort.Strings(envNames)
-
-		for i, envName := range envNames {
-			if i > 0 {
-				fmt.Fprintln(tmpFile)
-			}
-			fmt.Fprintf(tmpFile, "[%s]\n", envName)
-
-			env := decryptedEnvs[envName]
-			keys := make([]string, 0, len(env))
-			for k := range env {
-				keys = append(keys, k)
-			}
-			sort.Strings(keys)
-
-			for _, key := range keys {
-				fmt.Fprintf(tmpFile, "%s = %q\n", key, env[key])
-			}
+		if len(skipped) > 0 {
+			notes.WriteString("\n")
 		}
+		tmpFile.WriteString(notes.String())
+
+		// Write decrypted TOML, keeping comments and key order
+		tmpFile.Write(f.WithEnvironments(decryptedEnvs).Bytes())
 		tmpFile.Close()
 
-		// Run editor: $EDITOR, $VISUAL, VS Code, or vim
+		// Run editor: $EDITOR, $VISUAL, .sse.toml, VS Code, or vim
 		var editorCmd *exec.Cmd
-		if editor := os.Getenv("EDITOR"); editor != "" {
-			editorCmd = exec.Command(editor, tmpPath)
-		} else if editor := os.Getenv("VISUAL"); editor != "" {
-			editorCmd = exec.Command(editor, tmpPath)
+		editor, _ := setting("editor")
+		if args := strings.Fields(editor); len(args) > 0 {
+			editorCmd = exec.Command(args[0], append(args[1:], tmpPath)...)
 		} else if _, err := exec.LookPath("code"); err == nil {
 			editorCmd = exec.Command("code", "--wait", tmpPath)
 		} else {
@@ -104,32 +92,70 @@ Examples:
 			return fmt.Errorf("failed to read edited file: %w", err)
 		}
 
-		var editedEnvs map[string]map[string]string
-		if err := toml.Unmarshal(editedData, &editedEnvs); err != nil {
+		edited, err := secrets.Parse([]byte(strings.TrimPrefix(string(editedData), notes.String())))
+		if err != nil {
 			return fmt.Errorf("failed to parse edited TOML: %w", err)
 		}
 
-		// Encrypt all values
+		// Encrypt changed values, keeping the existing ciphertext for unchanged ones
 		encryptedEnvs := make(map[string]map[string]string)
-		for envName, env := range editedEnvs {
-			encrypted, err := secrets.EncryptEnvironment(env, recipient)
+		for envName, env := range edited.Environments {
+			if _, hidden := f.Environments[envName]; hidden && decryptedEnvs[envName] == nil {
+				return fmt.Errorf("cannot edit %s: not a recipient", envName)
+			}
+			if err := checkEditedKeys(env, f.Environments[envName], envName); err != nil {
+				return err
+			}
+			recips, err := recipientList.Recipients(envName)
+			if err != nil {
+				return err
+			}
+			encrypted, err := secrets.ReencryptEnvironment(env, decryptedEnvs[envName], f.Environments[envName], recips...)
 			if err != nil {
 				return fmt.Errorf("failed to encrypt %s: %w", envName, err)
 			}
+			edited.KeepLiterals(envName, encrypted)
 			encryptedEnvs[envName] = encrypted
 		}
 
-		// Save
-		f.Environments = encryptedEnvs
-		if err := f.Save(secrets.DefaultFile); err != nil {
+		// Keep the sections we could not decrypt
+		edited.Environments = encryptedEnvs
+		for _, envName := range skipped {
+			edited.CopyEnvironment(f, envName)
+		}
+
+		// Save, with the comments from the edited file
+		if err := edited.Save(secretsPath()); err != nil {
 			return err
 		}
 
-		fmt.Println("Saved env.toml")
+		fmt.Printf("Saved %s\n", secretsPath())
 		return nil
 	},
 }
 
+// checkEditedKeys rejects keys added in the editor that cannot be used as environment
+// variable names. Keys that were already in env.toml are only warned about, unless in strict mode.
+func checkEditedKeys(edited, original map[string]string, envName string) error {
+	keys := make([]string, 0, len(edited))
+	for key := range edited {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		err := secrets.ValidateKey(key)
+		if err == nil {
+			continue
+		}
+		if _, ok := original[key]; !ok || strictMode() {
+			return fmt.Errorf("%s: %w", envName, err)
+		}
+		fmt.Fprintf(os.Stderr, "Warning: %q in %s is not a valid variable name and is skipped when loading\n", key, envName)
+	}
+	return nil
+}
+
 func init() {
 	rootCmd.AddCommand(editCmd)
 }
@@ -0,0 +1,168 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"filippo.io/age"
+	"github.com/schrockwell/sse/internal/secrets"
+)
+
+// localPath returns the path of env.local.toml, next to env.toml.
+func localPath() string {
+	return secrets.LocalPathFor(secretsPath())
+}
+
+// loadLocalFile loads env.local.toml, or returns nil if there is none.
+func loadLocalFile() (*secrets.File, error) {
+	f, err := secrets.Load(localPath())
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	return f, err
+}
+
+// saveLocalFile writes env.local.toml, readable by the owner only.
+func saveLocalFile(local *secrets.File) error {
+	return writePrivateFile(localPath(), local.Bytes())
+}
+
+// environmentLayers returns the sections of env.toml and of env.local.toml that make up an
+// environment, in the order they are applied.
+func environmentLayers(f *secrets.File, envName string) ([]string, []string, error) {
+	layers, err := f.Layers(envName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// A [shared] section in env.local.toml applies even if env.toml has none
+	var localLayers []string
+	if !secrets.IsBase(envName) {
+		for _, base := range secrets.BaseEnvironments {
+			if !contains(layers, base) {
+				localLayers = append(localLayers, base)
+			}
+		}
+	}
+	return layers, append(localLayers, layers...), nil
+}
+
+// environmentKeys returns the sorted keys of an environment without decrypting it.
+func environmentKeys(f, local *secrets.File, envName string) ([]string, error) {
+	layers, localLayers, err := environmentLayers(f, envName)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	for _, layer := range layers {
+		for k := range f.Environments[layer] {
+			seen[k] = true
+		}
+	}
+	if local != nil {
+		for _, layer := range localLayers {
+			for k := range local.Environments[layer] {
+				seen[k] = true
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(seen))
+	for k := range seen {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// resolveEnvironment decrypts an environment, including the values it inherits, with the
+// matching sections of env.local.toml on top. It also returns where each value came from,
+// as the file name and section, e.g. "env.toml [shared]".
+func resolveEnvironment(f, local *secrets.File, identities []age.Identity, envName string) (map[string]string, map[string]string, error) {
+	layers, localLayers, err := environmentLayers(f, envName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values := make(map[string]string)
+	sources := make(map[string]string)
+	apply := func(file *secrets.File, path string, layers []string) error {
+		for _, layer := range layers {
+			decrypted, err := secrets.DecryptEnvironment(file.Environments[layer], identities...)
+			if err != nil {
+				return fmt.Errorf("failed to decrypt %s [%s]: %w", filepath.Base(path), layer, err)
+			}
+			for k, v := range decrypted {
+				values[k] = v
+				sources[k] = fmt.Sprintf("%s [%s]", filepath.Base(path), layer)
+			}
+		}
+		return nil
+	}
+
+	if err := apply(f, secretsPath(), layers); err != nil {
+		return nil, nil, err
+	}
+	if local != nil {
+		if err := apply(local, localPath(), localLayers); err != nil {
+			return nil, nil, err
+		}
+	}
+	return values, sources, nil
+}
+
+// decryptEnvironment returns the decrypted values of an environment, including the ones it
+// inherits and the overrides in env.local.toml, with ${...} references expanded.
+func decryptEnvironment(f, local *secrets.File, identities []age.Identity, envName string) (map[string]string, error) {
+	decrypt := func(name string) (map[string]string, error) {
+		values, _, err := resolveEnvironment(f, local, identities, name)
+		return values, err
+	}
+
+	decrypted, err := decrypt(envName)
+	if err != nil {
+		return nil, err
+	}
+	return secrets.Interpolate(envName, decrypted, decrypt)
+}
+
+// checkKeys returns the keys that can be used as environment variable names. Other keys are
+// skipped with a warning, or are an error in strict mode.
+func checkKeys(keys []string, envName string) ([]string, error) {
+	valid := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if err := secrets.ValidateKey(key); err != nil {
+			if strictMode() {
+				return nil, fmt.Errorf("%s: %w", envName, err)
+			}
+			fmt.Fprintf(os.Stderr, "Warning: skipping %q in %s: not a valid variable name\n", key, envName)
+			continue
+		}
+		valid = append(valid, key)
+	}
+	return valid, nil
+}
+
+// exportableEnvironment returns the values whose keys can be used as environment variable
+// names, see checkKeys.
+func exportableEnvironment(values map[string]string, envName string) (map[string]string, error) {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	valid, err := checkKeys(keys, envName)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]string, len(valid))
+	for _, k := range valid {
+		result[k] = values[k]
+	}
+	return result, nil
+}
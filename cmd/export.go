@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/schrockwell/sse/internal/format"
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportFormat string
+	exportOutput string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export [environment]",
+	Short: "Write decrypted values as dotenv, JSON, YAML, docker or TOML",
+	Long: `Decrypt an environment and write it in another format, for tools that
+read their configuration from a file.
+
+Formats:
+  dotenv  KEY='value' if the value has $, \ or " but no ', which every
+          dotenv reader takes literally; KEY="value" otherwise, with \n,
+          \", \\ and \$ escapes (default)
+  json    a JSON object
+  yaml    a YAML mapping with double-quoted values
+  docker  KEY=value for docker run --env-file; fails on multi-line values
+  toml    TOML key/value pairs
+
+The result goes to stdout, or with --output to a file that is readable
+by its owner only.
+
+Examples:
+  sse export                                   # development as dotenv
+  sse export production --format json
+  sse export production --format docker -o .env.docker
+  sse export --format yaml > vars.yml`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := defaultEnvironment()
+		if len(args) > 0 {
+			envName = args[0]
+		}
+
+		key, err := loadKey()
+		if err != nil {
+			return err
+		}
+
+		f, err := secrets.Load(secretsPath())
+		if err != nil {
+			return err
+		}
+
+		local, err := loadLocalFile()
+		if err != nil {
+			return err
+		}
+
+		decrypted, err := decryptEnvironment(f, local, key.Identities, envName)
+		if err != nil {
+			return err
+		}
+		decrypted, err = exportableEnvironment(decrypted, envName)
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+		if err := format.Write(&buf, exportFormat, decrypted); err != nil {
+			return err
+		}
+
+		if exportOutput == "" || exportOutput == "-" {
+			_, err := os.Stdout.Write(buf.Bytes())
+			return err
+		}
+
+		if err := writePrivateFile(exportOutput, buf.Bytes()); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d values from %s to %s\n", len(decrypted), envName, exportOutput)
+		return nil
+	},
+}
+
+// writePrivateFile writes data to a file that only its owner can read, tightening the
+// permissions of an existing file.
+func writePrivateFile(path string, data []byte) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if err := f.Chmod(0600); err != nil {
+		return fmt.Errorf("failed to set permissions of %s: %w", path, err)
+	}
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return f.Close()
+}
+
+func init() {
+	exportCmd.Flags().StringVar(&exportFormat, "format", "dotenv", "Output format: "+strings.Join(format.Names, ", "))
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Write to this file instead of stdout")
+	rootCmd.AddCommand(exportCmd)
+}
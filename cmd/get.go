@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var getCmd = &cobra.Command{
+	Use:   "get KEY",
+	Short: "Print a single decrypted value",
+	Long: `Decrypt and print the value stored under KEY.
+
+The value is resolved like in sse load: inherited values and env.local.toml
+overrides apply, and ${...} references are expanded.
+
+Examples:
+  sse get API_KEY                  # development (default)
+  sse get -e production API_KEY    # production`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := defaultEnvironment()
+		name := args[0]
+
+		key, err := loadKey()
+		if err != nil {
+			return err
+		}
+
+		f, err := secrets.Load(secretsPath())
+		if err != nil {
+			return err
+		}
+
+		local, err := loadLocalFile()
+		if err != nil {
+			return err
+		}
+
+		env, err := decryptEnvironment(f, local, key.Identities, envName)
+		if err != nil {
+			return err
+		}
+
+		decrypted, ok := env[name]
+		if !ok {
+			return fmt.Errorf("key %q not found in %s", name, envName)
+		}
+
+		fmt.Println(decrypted)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(getCmd)
+}
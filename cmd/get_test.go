@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"os"
+	"testing"
+)
+
+func TestGet(t *testing.T) {
+	t.Run("resolves values like load", func(t *testing.T) {
+		newProject(t)
+		runSSE(t, "set", "HOST", "db.internal")
+		runSSE(t, "set", "DATABASE_URL", "postgres://${HOST}/app")
+		runSSE(t, "set", "-e", "production", "HOST", "db.prod")
+		runSSE(t, "set", "--local", "HOST", "localhost")
+
+		tests := map[string][]string{
+			"postgres://localhost/app\n": {"get", "DATABASE_URL"},
+			"db.prod\n":                  {"get", "-e", "production", "HOST"},
+		}
+		for want, args := range tests {
+			if got := runSSE(t, args...); got != want {
+				t.Errorf("sse %v = %q, want %q", args, got, want)
+			}
+		}
+	})
+
+	t.Run("includes inherited values", func(t *testing.T) {
+		newProject(t)
+		data := "[shared]\nREGION = \"eu\"\n\n[development]\n"
+		if err := os.WriteFile("env.toml", []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if got := runSSE(t, "get", "REGION"); got != "eu\n" {
+			t.Errorf("get REGION = %q, want %q", got, "eu\n")
+		}
+		if _, err := trySSE(t, "get", "MISSING"); err == nil {
+			t.Error("get MISSING should have failed")
+		}
+	})
+}
@@ -0,0 +1,176 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/schrockwell/sse/internal/dotenv"
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var importOnConflict string
+
+var importCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import values from a .env file",
+	Long: `Encrypt the values of a dotenv file and add them to an environment.
+
+The file may use export prefixes, single- and double-quoted values,
+escapes such as \n in double quotes, multi-line quoted values and
+# comments. Use - to read from stdin. Values are imported as written, so
+a ${...} in them is stored as $${...} and not expanded.
+
+A key that is already set to a different value is a conflict. With
+--on-conflict, choose whether to skip it, overwrite it, or fail without
+changing anything (the default). Keys set to the same value are left
+untouched.
+
+Examples:
+  sse import .env                            # into development (default)
+  sse import -e production .env.production
+  sse import --on-conflict skip .env         # keep existing values
+  cat .env | sse import --on-conflict overwrite -`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := defaultEnvironment()
+
+		switch importOnConflict {
+		case "skip", "overwrite", "fail":
+		default:
+			return fmt.Errorf("invalid --on-conflict %q (use skip, overwrite or fail)", importOnConflict)
+		}
+
+		entries, err := readDotenv(args[0])
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			if err := secrets.ValidateKey(entry.Key); err != nil {
+				return fmt.Errorf("failed to import %s: %w", args[0], err)
+			}
+		}
+
+		f, err := secrets.Load(secretsPath())
+		if err != nil {
+			return err
+		}
+
+		// Later assignments of the same key win, as in a shell
+		values := make(map[string]string)
+		var keys []string
+		for _, entry := range entries {
+			if _, ok := values[entry.Key]; !ok {
+				keys = append(keys, entry.Key)
+			}
+			values[entry.Key] = entry.Value
+		}
+
+		var added, changed, skipped, unchanged []string
+		existing := f.Environments[envName]
+		for _, key := range keys {
+			current, ok := existing[key]
+			if !ok {
+				added = append(added, key)
+				continue
+			}
+			same, err := sameValue(current, secrets.EscapeReferences(values[key]))
+			if err != nil {
+				return err
+			}
+			switch {
+			case same:
+				unchanged = append(unchanged, key)
+			case importOnConflict == "skip":
+				skipped = append(skipped, key)
+			default:
+				changed = append(changed, key)
+			}
+		}
+
+		if importOnConflict == "fail" && len(changed) > 0 {
+			return fmt.Errorf("already set in %s: %s (use --on-conflict skip or overwrite)", envName, strings.Join(changed, ", "))
+		}
+
+		recips, err := loadRecipients(envName)
+		if err != nil {
+			return err
+		}
+		for _, key := range append(added, changed...) {
+			// A dotenv value is literal text, not a reference to another key
+			encrypted, err := secrets.EncryptValue(secrets.EscapeReferences(values[key]), recips...)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s: %w", key, err)
+			}
+			f.Set(envName, key, encrypted)
+		}
+
+		if len(added)+len(changed) > 0 {
+			if err := f.Save(secretsPath()); err != nil {
+				return err
+			}
+		}
+
+		for _, key := range added {
+			fmt.Fprintf(os.Stderr, "  + %s\n", key)
+		}
+		for _, key := range changed {
+			fmt.Fprintf(os.Stderr, "  ~ %s\n", key)
+		}
+		for _, key := range skipped {
+			fmt.Fprintf(os.Stderr, "  = %s (skipped, already set)\n", key)
+		}
+		fmt.Fprintf(os.Stderr, "Imported into %s: %d added, %d changed, %d skipped, %d unchanged\n",
+			envName, len(added), len(changed), len(skipped), len(unchanged))
+		return nil
+	},
+}
+
+// readDotenv parses a dotenv file, or stdin if path is "-".
+func readDotenv(path string) ([]dotenv.Entry, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	entries, err := dotenv.Parse(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return entries, nil
+}
+
+// sameValue reports whether a value stored in env.toml decrypts to value. Values that
+// cannot be decrypted with the current identity are never the same.
+func sameValue(stored, value string) (bool, error) {
+	if !secrets.IsEncrypted(stored) {
+		return stored == value, nil
+	}
+
+	key, err := loadKey()
+	if err != nil {
+		return false, err
+	}
+	decrypted, err := secrets.DecryptValue(stored, key.Identities...)
+	if secrets.IsNoIdentityMatch(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return decrypted == value, nil
+}
+
+func init() {
+	importCmd.Flags().StringVar(&importOnConflict, "on-conflict", "fail", "What to do with keys that are already set: skip, overwrite or fail")
+	rootCmd.AddCommand(importCmd)
+}
@@ -0,0 +1,28 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestImport(t *testing.T) {
+	t.Run("keeps literal references", func(t *testing.T) {
+		newProject(t)
+		data := "HOME_DIR='${HOME}'\nESCAPED=\"\\${X} and $${Y}\"\nOPEN='${'\n"
+		if err := os.WriteFile(".env", []byte(data), 0600); err != nil {
+			t.Fatal(err)
+		}
+		runSSE(t, "import", ".env")
+
+		out := runSSE(t, "load", "--shell", "posix")
+		for _, want := range []string{"export HOME_DIR='${HOME}'", "export ESCAPED='${X} and $${Y}'", "export OPEN='${'"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("load =\n%s\nwant it to contain %s", out, want)
+			}
+		}
+
+		// Importing the same file again finds every value unchanged rather than in conflict
+		runSSE(t, "import", ".env")
+	})
+}
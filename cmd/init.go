@@ -1,9 +1,9 @@
 package cmd
 
 import (
-	"bufio"
 	"fmt"
 	"os"
+	"path/filepath"
 	"strings"
 
 	"github.com/schrockwell/sse/internal/keyfile"
@@ -12,6 +12,7 @@ import (
 )
 
 var initForce bool
+var initPassphrase bool
 
 var initCmd = &cobra.Command{
 	Use:   "init",
@@ -19,61 +20,94 @@ var initCmd = &cobra.Command{
 	Long: `Initialize a new project by creating:
 - master.key: age keypair for encryption/decryption
 - env.toml: secrets file with development and production sections
+  (or the environments listed in .sse.toml)
 
-Automatically adds master.key to .gitignore if it exists.
-The env.toml file is safe to commit (values are encrypted).`,
+Automatically adds master.key and env.local.toml to .gitignore if it exists.
+The env.toml file is safe to commit (values are encrypted).
+
+With --passphrase, master.key is encrypted with a passphrase.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		// A new project always starts in the current directory, even inside another one
+		rootDir = "."
+		return loadProjectConfig()
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
 		// Generate master.key
-		if err := keyfile.Generate(keyfile.DefaultKeyFile, initForce); err != nil {
+		var passphrase string
+		if initPassphrase {
+			p, err := keyfile.ReadNewPassphrase()
+			if err != nil {
+				return err
+			}
+			passphrase = p
+		}
+		if err := keyfile.GenerateProtected(keyPath(), initForce, passphrase); err != nil {
 			return err
 		}
-		fmt.Printf("Created %s\n", keyfile.DefaultKeyFile)
+		fmt.Printf("Created %s\n", keyPath())
 
 		// Create env.toml if it doesn't exist
-		if _, err := os.Stat(secrets.DefaultFile); os.IsNotExist(err) || initForce {
-			if err := secrets.CreateDefault(secrets.DefaultFile); err != nil {
-				return fmt.Errorf("failed to create env.toml: %w", err)
+		if _, err := os.Stat(secretsPath()); os.IsNotExist(err) || initForce {
+			if err := secrets.Create(secretsPath(), projectEnvironments()...); err != nil {
+				return fmt.Errorf("failed to create %s: %w", secretsPath(), err)
 			}
-			fmt.Printf("Created %s\n", secrets.DefaultFile)
+			fmt.Printf("Created %s\n", secretsPath())
 		} else {
-			fmt.Printf("Skipped %s (already exists)\n", secrets.DefaultFile)
+			fmt.Printf("Skipped %s (already exists)\n", secretsPath())
 		}
 
-		// Add to .gitignore if it exists
-		if err := addToGitignore("/master.key"); err != nil {
-			fmt.Printf("Warning: %v\n", err)
+		// Add master.key and env.local.toml to .gitignore if it exists
+		for _, path := range []string{keyPath(), localPath()} {
+			if entry, ok := gitignoreEntry(path); ok {
+				if err := addToGitignore(entry); err != nil {
+					fmt.Printf("Warning: %v\n", err)
+				}
+			}
 		}
 
 		return nil
 	},
 }
 
+// gitignoreEntry returns the .gitignore entry for a path, anchored to the project root.
+// Paths outside the project root can't be ignored and return false.
+func gitignoreEntry(path string) (string, bool) {
+	root, err := filepath.Abs(projectRoot())
+	if err != nil {
+		return "", false
+	}
+	path, err = filepath.Abs(path)
+	if err != nil {
+		return "", false
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return "", false
+	}
+	if rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+	return "/" + filepath.ToSlash(rel), true
+}
+
 func addToGitignore(entry string) error {
-	const gitignore = ".gitignore"
+	gitignore := filepath.Join(projectRoot(), ".gitignore")
 
-	// Check if .gitignore exists
-	if _, err := os.Stat(gitignore); os.IsNotExist(err) {
+	// Read .gitignore if it exists
+	data, err := os.ReadFile(gitignore)
+	if os.IsNotExist(err) {
 		return nil // No .gitignore, nothing to do
 	}
-
-	// Read and check if entry already exists
-	file, err := os.Open(gitignore)
 	if err != nil {
 		return fmt.Errorf("failed to read .gitignore: %w", err)
 	}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		if strings.TrimSpace(scanner.Text()) == entry {
-			file.Close()
+	// Check if entry already exists
+	for _, line := range strings.Split(string(data), "\n") {
+		if strings.TrimSpace(line) == entry {
 			return nil // Already in .gitignore
 		}
 	}
-	file.Close()
-
-	if err := scanner.Err(); err != nil {
-		return fmt.Errorf("failed to read .gitignore: %w", err)
-	}
 
 	// Append entry
 	f, err := os.OpenFile(gitignore, os.O_APPEND|os.O_WRONLY, 0644)
@@ -83,15 +117,9 @@ func addToGitignore(entry string) error {
 	defer f.Close()
 
 	// Add newline if file doesn't end with one
-	info, _ := f.Stat()
-	if info.Size() > 0 {
-		// Read last byte to check for newline
-		buf := make([]byte, 1)
-		f.Seek(-1, 2)
-		f.Read(buf)
-		f.Seek(0, 2) // Back to end
-		if buf[0] != '\n' {
-			f.WriteString("\n")
+	if len(data) > 0 && data[len(data)-1] != '\n' {
+		if _, err := f.WriteString("\n"); err != nil {
+			return fmt.Errorf("failed to write to .gitignore: %w", err)
 		}
 	}
 
@@ -105,5 +133,6 @@ func addToGitignore(entry string) error {
 
 func init() {
 	initCmd.Flags().BoolVarP(&initForce, "force", "f", false, "Overwrite existing files")
+	initCmd.Flags().BoolVar(&initPassphrase, "passphrase", false, "Protect master.key with a passphrase")
 	rootCmd.AddCommand(initCmd)
 }
@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/schrockwell/sse/internal/keyfile"
+	"github.com/spf13/cobra"
+)
+
+var keyCmd = &cobra.Command{
+	Use:   "key",
+	Short: "Manage passphrase protection of master.key",
+	Long: `Protect master.key with a passphrase, or remove the protection.
+
+A protected master.key is wrapped in an age scrypt-encrypted envelope, so a
+copy of the file alone does not expose any secrets. Commands ask for the
+passphrase on the terminal, or read it from the file descriptor named by
+SSE_PASSPHRASE_FD for non-interactive use:
+
+  SSE_PASSPHRASE_FD=3 sse show 3<<<"$PASSPHRASE"`,
+}
+
+var keyProtectCmd = &cobra.Command{
+	Use:   "protect",
+	Short: "Encrypt master.key with a passphrase",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passphrase, err := keyfile.ReadNewPassphrase()
+		if err != nil {
+			return err
+		}
+		if err := keyfile.Protect(keyPath(), passphrase); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Protected %s\n", keyPath())
+		return nil
+	},
+}
+
+var keyUnprotectCmd = &cobra.Command{
+	Use:   "unprotect",
+	Short: "Remove the passphrase from master.key",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		passphrase, err := keyfile.ReadPassphrase(fmt.Sprintf("Enter passphrase for %s: ", keyPath()))
+		if err != nil {
+			return err
+		}
+		if err := keyfile.Unprotect(keyPath(), passphrase); err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Removed passphrase from %s\n", keyPath())
+		return nil
+	},
+}
+
+func init() {
+	keyCmd.AddCommand(keyProtectCmd)
+	keyCmd.AddCommand(keyUnprotectCmd)
+	rootCmd.AddCommand(keyCmd)
+}
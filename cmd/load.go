@@ -2,48 +2,87 @@ package cmd
 
 import (
 	"fmt"
+	"os"
 	"sort"
 	"strings"
 
-	"github.com/schrockwell/sse/internal/keyfile"
 	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/schrockwell/sse/internal/shell"
 	"github.com/spf13/cobra"
 )
 
+var (
+	loadShell string
+	loadUnset bool
+)
+
 var loadCmd = &cobra.Command{
 	Use:   "load [environment]",
 	Short: "Export variables to current shell",
-	Long: `Output export statements for the specified environment.
-Use with eval to load into your current shell.
+	Long: `Output statements that set the variables of the specified environment.
+Use with eval, or your shell's equivalent, to load them into your current shell.
+
+The shell is detected from $SHELL, or chosen with --shell: posix (sh, bash,
+zsh), fish, pwsh (PowerShell), nu (nushell) or cmd (batch files; values
+cannot span lines). With --unset, the statements remove the variables of
+the environment instead, without decrypting anything.
 
 Examples:
-  eval "$(sse load)"             # load development (default)
-  eval "$(sse load production)"  # load production`,
+  eval "$(sse load)"                                    # load development (default)
+  eval "$(sse load production)"                         # load production
+  eval "$(sse load --unset)"                            # remove them again
+  sse load | source                                     # fish
+  sse load --shell pwsh | Out-String | Invoke-Expression
+  sse load --shell nu | save -f secrets.nu              # then: source secrets.nu
+  sse load --shell cmd > secrets.cmd && call secrets.cmd`,
 	Args: cobra.MaximumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		envName := secrets.DefaultEnvironment
+		envName := defaultEnvironment()
 		if len(args) > 0 {
 			envName = args[0]
 		}
 
-		identity, err := keyfile.LoadIdentity()
+		dialect := loadShell
+		if dialect == "" {
+			dialect = shell.Detect(os.Getenv("SHELL"))
+		}
+
+		f, err := secrets.Load(secretsPath())
 		if err != nil {
 			return err
 		}
 
-		f, err := secrets.Load(secrets.DefaultFile)
+		local, err := loadLocalFile()
 		if err != nil {
 			return err
 		}
 
-		env, err := f.GetEnvironment(envName)
+		if loadUnset {
+			keys, err := environmentKeys(f, local, envName)
+			if err != nil {
+				return err
+			}
+			keys, err = checkKeys(keys, envName)
+			if err != nil {
+				return err
+			}
+			return printStatements(keys, func(key string) (string, error) {
+				return shell.Unset(dialect, key)
+			})
+		}
+
+		key, err := loadKey()
 		if err != nil {
 			return err
 		}
 
-		decrypted, err := secrets.DecryptEnvironment(env, identity)
+		decrypted, err := decryptEnvironment(f, local, key.Identities, envName)
+		if err != nil {
+			return err
+		}
+		decrypted, err = exportableEnvironment(decrypted, envName)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt: %w", err)
+			return err
 		}
 
 		// Sort keys for consistent output
@@ -53,16 +92,29 @@ Examples:
 		}
 		sort.Strings(keys)
 
-		for _, key := range keys {
-			// Use single quotes to prevent shell expansion, escape embedded single quotes
-			escaped := strings.ReplaceAll(decrypted[key], "'", "'\"'\"'")
-			fmt.Printf("export %s='%s'\n", key, escaped)
-		}
-
-		return nil
+		return printStatements(keys, func(key string) (string, error) {
+			return shell.Set(dialect, key, decrypted[key])
+		})
 	},
 }
 
+// printStatements prints one statement per key, or nothing if any of them fails.
+func printStatements(keys []string, statement func(key string) (string, error)) error {
+	var b strings.Builder
+	for _, key := range keys {
+		s, err := statement(key)
+		if err != nil {
+			return err
+		}
+		b.WriteString(s)
+		b.WriteByte('\n')
+	}
+	fmt.Print(b.String())
+	return nil
+}
+
 func init() {
+	loadCmd.Flags().StringVar(&loadShell, "shell", "", "Shell to output statements for: "+strings.Join(shell.Dialects, ", ")+" (default detected from $SHELL)")
+	loadCmd.Flags().BoolVar(&loadUnset, "unset", false, "Output statements that remove the variables instead")
 	rootCmd.AddCommand(loadCmd)
 }
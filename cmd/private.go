@@ -2,20 +2,24 @@ package cmd
 
 import (
 	"fmt"
+	"strings"
 
-	"github.com/schrockwell/sse/internal/keyfile"
 	"github.com/spf13/cobra"
 )
 
 var privateCmd = &cobra.Command{
 	Use:   "private",
 	Short: "Print the private key from master.key",
+	Long: `Print the private key from master.key, in the form SSE_MASTER_KEY accepts.
+
+If master.key holds several secret keys, such as after sse rekey --keep-old, all of
+them are printed, primary first, one per line.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		identity, err := keyfile.LoadIdentity()
+		key, err := loadKey()
 		if err != nil {
 			return err
 		}
-		fmt.Println(identity.String())
+		fmt.Println(strings.TrimSpace(key.Private))
 		return nil
 	},
 }
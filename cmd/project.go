@@ -0,0 +1,57 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/schrockwell/sse/internal/keyfile"
+	"github.com/schrockwell/sse/internal/project"
+	"github.com/spf13/cobra"
+)
+
+var projectRootCmd = &cobra.Command{
+	Use:   "root",
+	Short: "Print the project root and resolved file paths",
+	Long: `Print the project root and the paths sse resolved for env.toml, master.key
+and .sse-recipients. Useful for debugging which files a command will use.
+
+The project root is the nearest directory, starting from the current one,
+that contains env.toml or .sse.toml.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		wd, err := os.Getwd()
+		if err != nil {
+			return fmt.Errorf("failed to get working directory: %w", err)
+		}
+		root, err := project.Find(wd)
+		if err != nil {
+			return err
+		}
+
+		key := absPath(keyPath())
+		if identityFile != "" {
+			key = absPath(identityFile) + " (--identity)"
+		} else if os.Getenv(keyfile.MasterKeyEnvVar) != "" {
+			key = keyfile.MasterKeyEnvVar
+		}
+
+		fmt.Printf("root        %s\n", root)
+		fmt.Printf("file        %s\n", absPath(secretsPath()))
+		fmt.Printf("key         %s\n", key)
+		fmt.Printf("recipients  %s\n", absPath(recipientsPath()))
+		return nil
+	},
+}
+
+// absPath returns the absolute form of path, or path itself if it can't be resolved.
+func absPath(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+	return path
+}
+
+func init() {
+	rootCmd.AddCommand(projectRootCmd)
+}
@@ -3,7 +3,6 @@ package cmd
 import (
 	"fmt"
 
-	"github.com/schrockwell/sse/internal/keyfile"
 	"github.com/spf13/cobra"
 )
 
@@ -11,11 +10,11 @@ var publicCmd = &cobra.Command{
 	Use:   "public",
 	Short: "Print the public key from master.key",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		recipient, err := keyfile.LoadRecipient()
+		key, err := loadKey()
 		if err != nil {
 			return err
 		}
-		fmt.Println(recipient.String())
+		fmt.Println(key.PublicKey)
 		return nil
 	},
 }
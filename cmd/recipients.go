@@ -0,0 +1,319 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"github.com/schrockwell/sse/internal/recipients"
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var recipientsEnv string
+var recipientsFrom string
+
+var recipientsCmd = &cobra.Command{
+	Use:   "recipients",
+	Short: "Manage the public keys that can decrypt env.toml",
+	Long: `Manage the list of public keys that values in env.toml are encrypted to.
+
+The list is stored in .sse-recipients next to env.toml and is safe to commit.
+Without that file, values are encrypted to the public key in master.key only.
+Each team member can decrypt with their own identity.
+
+Use --env to give a single environment its own, usually smaller, set of
+recipients. That environment is then encrypted to those keys only.
+
+Adding or removing a recipient re-encrypts every affected environment, so
+the current identity must be able to decrypt them.`,
+}
+
+var recipientsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List recipients",
+	Long: `List the recipients in .sse-recipients.
+
+With --env, list the recipients that the environment is encrypted to.`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		l, err := loadRecipientList()
+		if err != nil {
+			return err
+		}
+
+		if recipientsEnv != "" {
+			for _, key := range l.For(recipientsEnv) {
+				fmt.Println(key)
+			}
+			return nil
+		}
+
+		for _, key := range l.Keys {
+			fmt.Println(key)
+		}
+		for _, envName := range l.EnvironmentNames() {
+			fmt.Printf("\n[%s]\n", envName)
+			for _, key := range l.Environments[envName] {
+				fmt.Println(key)
+			}
+		}
+		return nil
+	},
+}
+
+var recipientsAddCmd = &cobra.Command{
+	Use:   "add PUBLIC_KEY...",
+	Short: "Add recipients and re-encrypt env.toml",
+	Long: `Add one or more public keys to .sse-recipients and re-encrypt every value.
+
+Public keys can be age keys or ssh-ed25519/ssh-rsa keys. With --from,
+keys are read one per line from a file such as authorized_keys.
+
+If .sse-recipients does not exist yet, it is created with your own public
+key so that you keep access. The same applies when --env gives an
+environment its own recipients for the first time.
+
+Examples:
+  sse recipients add age1...                         # all environments
+  sse recipients add -e production age1...           # production only
+  sse recipients add "ssh-ed25519 AAAA... alice"     # SSH public key
+  sse recipients add --from ~/.ssh/authorized_keys   # every key in a file`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if recipientsFrom != "" {
+			keys, err := readKeyLines(recipientsFrom)
+			if err != nil {
+				return err
+			}
+			args = append(args, keys...)
+		}
+		if len(args) == 0 {
+			return fmt.Errorf("no public keys given")
+		}
+
+		l, err := loadRecipientList()
+		if err != nil {
+			return err
+		}
+
+		changed := false
+		if _, ok := l.Environments[recipientsEnv]; recipientsEnv != "" && !ok {
+			own, err := loadKey()
+			if err != nil {
+				return err
+			}
+			if _, err := l.Add(recipientsEnv, own.PublicKey); err != nil {
+				return err
+			}
+			changed = true
+		}
+
+		for _, key := range args {
+			added, err := l.Add(recipientsEnv, key)
+			if err != nil {
+				return err
+			}
+			if added {
+				changed = true
+			} else {
+				fmt.Fprintf(os.Stderr, "Skipped %s (already a recipient)\n", key)
+			}
+		}
+
+		if !changed {
+			return nil
+		}
+		return saveRecipientList(l)
+	},
+}
+
+var recipientsRemoveCmd = &cobra.Command{
+	Use:   "remove PUBLIC_KEY...",
+	Short: "Remove recipients and re-encrypt env.toml",
+	Long: `Remove one or more public keys from .sse-recipients and re-encrypt every value.
+
+Note that a removed recipient can still decrypt old copies of env.toml,
+for example from version control history. Rotate the secrets themselves
+if that matters.
+
+Examples:
+  sse recipients remove age1...                  # all environments
+  sse recipients remove -e production age1...    # production only`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		l, err := loadRecipientList()
+		if err != nil {
+			return err
+		}
+
+		if _, ok := l.Environments[recipientsEnv]; recipientsEnv != "" && !ok {
+			return fmt.Errorf("%s has no recipients of its own", recipientsEnv)
+		}
+
+		for _, key := range args {
+			if !l.Remove(recipientsEnv, key) {
+				return fmt.Errorf("%s is not a recipient", key)
+			}
+		}
+
+		keys := l.Keys
+		if recipientsEnv != "" {
+			keys = l.Environments[recipientsEnv]
+		}
+		if len(keys) == 0 {
+			return fmt.Errorf("cannot remove the last recipient")
+		}
+
+		if own, err := loadKey(); err == nil {
+			found := false
+			for _, key := range keys {
+				if recipients.Same(key, own.PublicKey) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				fmt.Fprintln(os.Stderr, "Warning: your own public key is no longer a recipient; you will not be able to decrypt the affected environments")
+			}
+		}
+
+		return saveRecipientList(l)
+	},
+}
+
+// readKeyLines reads public keys from a file, one per line, skipping blank lines and comments.
+func readKeyLines(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var keys []string
+	for _, line := range strings.Split(string(data), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		keys = append(keys, line)
+	}
+	return keys, nil
+}
+
+// loadRecipientList reads .sse-recipients, or builds a list holding our own public key if it doesn't exist.
+func loadRecipientList() (*recipients.List, error) {
+	l, err := recipients.Load(recipientsPath())
+	if err == nil {
+		return l, nil
+	}
+	if !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	key, err := loadKey()
+	if err != nil {
+		return nil, err
+	}
+	return &recipients.List{Keys: []string{key.PublicKey}}, nil
+}
+
+// loadRecipients returns the recipients that values in the named environment should be encrypted to.
+func loadRecipients(envName string) ([]age.Recipient, error) {
+	l, err := loadRecipientList()
+	if err != nil {
+		return nil, err
+	}
+	return l.Recipients(envName)
+}
+
+// saveRecipientList re-encrypts every environment whose recipients changed, then saves both files.
+func saveRecipientList(l *recipients.List) error {
+	old, err := loadRecipientList()
+	if err != nil {
+		return err
+	}
+
+	f, err := secrets.Load(secretsPath())
+	if err != nil {
+		return err
+	}
+
+	var changed []string
+	for envName := range f.Environments {
+		if !sameKeys(old.For(envName), l.For(envName)) {
+			changed = append(changed, envName)
+		}
+	}
+	sort.Strings(changed)
+
+	if len(changed) > 0 {
+		key, err := loadKey()
+		if err != nil {
+			return err
+		}
+		if err := reencrypt(f, key.Identities, l, changed); err != nil {
+			return err
+		}
+		if err := f.Save(secretsPath()); err != nil {
+			return err
+		}
+	}
+
+	if err := l.Save(recipientsPath()); err != nil {
+		return err
+	}
+
+	for _, envName := range changed {
+		fmt.Fprintf(os.Stderr, "Re-encrypted %s for %d recipient(s)\n", envName, len(l.For(envName)))
+	}
+	return nil
+}
+
+// reencrypt decrypts the named environments in f with any of the identities and encrypts them again to their recipients in l.
+func reencrypt(f *secrets.File, identities []age.Identity, l *recipients.List, envNames []string) error {
+	for _, envName := range envNames {
+		recips, err := l.Recipients(envName)
+		if err != nil {
+			return err
+		}
+		decrypted, err := secrets.DecryptEnvironment(f.Environments[envName], identities...)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", envName, err)
+		}
+		encrypted, err := secrets.EncryptEnvironment(decrypted, recips...)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", envName, err)
+		}
+		f.KeepLiterals(envName, encrypted)
+		f.Environments[envName] = encrypted
+	}
+	return nil
+}
+
+// sameKeys reports whether a and b hold the same keys, ignoring order.
+func sameKeys(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	seen := make(map[string]bool, len(a))
+	for _, key := range a {
+		seen[key] = true
+	}
+	for _, key := range b {
+		if !seen[key] {
+			return false
+		}
+	}
+	return true
+}
+
+func init() {
+	recipientsCmd.PersistentFlags().StringVarP(&recipientsEnv, "env", "e", "", "Environment with its own recipients")
+	recipientsAddCmd.Flags().StringVar(&recipientsFrom, "from", "", "Read public keys from a file, one per line")
+	recipientsCmd.AddCommand(recipientsListCmd)
+	recipientsCmd.AddCommand(recipientsAddCmd)
+	recipientsCmd.AddCommand(recipientsRemoveCmd)
+	rootCmd.AddCommand(recipientsCmd)
+}
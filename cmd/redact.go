@@ -0,0 +1,65 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/schrockwell/sse/internal/redact"
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var redactMinLength int
+
+var redactCmd = &cobra.Command{
+	Use:   "redact [environment]",
+	Short: "Mask secret values in text from stdin",
+	Long: `Copy stdin to stdout, replacing every decrypted value of the environment
+with ***KEY***, where KEY is the name of the value. Values shorter than
+--min-length are left alone.
+
+Examples:
+  ./run-tests.sh 2>&1 | sse redact
+  kubectl logs deploy/web | sse redact production
+  sse redact --min-length 1 < config.log`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := defaultEnvironment()
+		if len(args) > 0 {
+			envName = args[0]
+		}
+
+		key, err := loadKey()
+		if err != nil {
+			return err
+		}
+
+		f, err := secrets.Load(secretsPath())
+		if err != nil {
+			return err
+		}
+
+		local, err := loadLocalFile()
+		if err != nil {
+			return err
+		}
+
+		decrypted, err := decryptEnvironment(f, local, key.Identities, envName)
+		if err != nil {
+			return err
+		}
+
+		w := redact.NewWriter(os.Stdout, decrypted, redactMinLength)
+		if _, err := io.Copy(w, os.Stdin); err != nil {
+			w.Flush()
+			return fmt.Errorf("failed to redact: %w", err)
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	redactCmd.Flags().IntVar(&redactMinLength, "min-length", redact.DefaultMinLength, "Do not mask values shorter than this")
+	rootCmd.AddCommand(redactCmd)
+}
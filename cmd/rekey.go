@@ -0,0 +1,275 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"filippo.io/age"
+	"github.com/schrockwell/sse/internal/keyfile"
+	"github.com/schrockwell/sse/internal/recipients"
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var rekeyKeepOld bool
+
+var rekeyCmd = &cobra.Command{
+	Use:   "rekey",
+	Short: "Rotate master.key and re-encrypt every value",
+	Long: `Generate a new master.key and re-encrypt every environment with it.
+
+All values are decrypted with the current identity, encrypted to the new
+one, and checked to decrypt back to the same plaintext before anything is
+written. The old key is kept as master.key.<timestamp>.bak, and its public
+key is replaced by the new one in .sse-recipients.
+
+With --keep-old, the old identities stay in master.key after the new one,
+so values on other branches that are still encrypted to the old key can
+be decrypted during the rotation.
+
+Encrypted values in env.local.toml are re-encrypted to the new key as well.
+
+The new public key is printed on success. Rekeying an identity given with
+--identity is refused, since only master.key is replaced.
+
+Examples:
+  sse rekey
+  sse rekey --keep-old`,
+	Args: cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		// The new key is written to master.key, which the identity file would not pick up
+		if identityFile != "" {
+			return fmt.Errorf("cannot rekey with --identity; rekey replaces %s, not %s", keyPath(), identityFile)
+		}
+
+		oldKey, err := loadKey()
+		if err != nil {
+			return err
+		}
+		if oldKey.IsSSH() {
+			return fmt.Errorf("cannot rekey an SSH identity; rotate the SSH key and update %s instead", recipientsPath())
+		}
+
+		f, err := secrets.Load(secretsPath())
+		if err != nil {
+			return err
+		}
+
+		decryptedEnvs, skipped, err := f.DecryptAll(oldKey.Identities...)
+		if err != nil {
+			return err
+		}
+		if len(skipped) > 0 {
+			return fmt.Errorf("cannot rekey: not a recipient of %s", skipped[0])
+		}
+
+		recipientList, err := loadRecipientList()
+		if err != nil {
+			return err
+		}
+
+		newIdentity, err := age.GenerateX25519Identity()
+		if err != nil {
+			return fmt.Errorf("failed to generate keypair: %w", err)
+		}
+
+		newKey := newIdentity.Recipient().String()
+		if recipientList.Replace(oldKey.PublicKey, newKey) == 0 {
+			return fmt.Errorf("cannot rekey: %s is not listed in %s", oldKey.PublicKey, recipientsPath())
+		}
+
+		// Encrypt everything to the new recipients and check that it round-trips
+		rekeyed := f.WithEnvironments(make(map[string]map[string]string))
+		for envName, decrypted := range decryptedEnvs {
+			recips, err := recipientList.Recipients(envName)
+			if err != nil {
+				return err
+			}
+			encrypted, err := secrets.EncryptEnvironment(decrypted, recips...)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt %s: %w", envName, err)
+			}
+			f.KeepLiterals(envName, encrypted)
+			if err := secrets.VerifyEnvironment(encrypted, decrypted, newIdentity); err != nil {
+				return fmt.Errorf("verification of %s failed: %w", envName, err)
+			}
+			rekeyed.Environments[envName] = encrypted
+		}
+
+		// Values in env.local.toml are encrypted to this key only
+		local, err := loadLocalFile()
+		if err != nil {
+			return err
+		}
+		var rekeyedLocal *secrets.File
+		if local != nil {
+			rekeyedLocal, err = rekeyLocal(local, oldKey.Identities, newIdentity)
+			if err != nil {
+				return err
+			}
+		}
+
+		keep := []*age.X25519Identity{newIdentity}
+		if rekeyKeepOld {
+			keep = append(keep, oldKey.X25519Identities()...)
+		}
+
+		if err := commitRekey(rekeyed, rekeyedLocal, keep, recipientList); err != nil {
+			return err
+		}
+
+		if os.Getenv(keyfile.MasterKeyEnvVar) != "" {
+			fmt.Fprintf(os.Stderr, "Warning: %s is set and still holds the old key; update or unset it\n", keyfile.MasterKeyEnvVar)
+		}
+
+		fmt.Println(newKey)
+		return nil
+	},
+}
+
+// rekeyLocal re-encrypts the encrypted values of env.local.toml to the new identity and checks
+// that they round-trip. Plaintext values stay as they are.
+func rekeyLocal(local *secrets.File, identities []age.Identity, newIdentity *age.X25519Identity) (*secrets.File, error) {
+	rekeyed := local.WithEnvironments(make(map[string]map[string]string))
+	for envName, env := range local.Environments {
+		decrypted, err := secrets.DecryptEnvironment(env, identities...)
+		if err != nil {
+			return nil, fmt.Errorf("cannot rekey: failed to decrypt %s [%s]: %w", filepath.Base(localPath()), envName, err)
+		}
+
+		encrypted := make(map[string]string)
+		for key, value := range env {
+			if !secrets.IsEncrypted(value) {
+				encrypted[key] = value
+				continue
+			}
+			enc, err := secrets.EncryptValue(decrypted[key], newIdentity.Recipient())
+			if err != nil {
+				return nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
+			}
+			encrypted[key] = enc
+		}
+		local.KeepLiterals(envName, encrypted)
+		if err := secrets.VerifyEnvironment(encrypted, decrypted, newIdentity); err != nil {
+			return nil, fmt.Errorf("verification of %s [%s] failed: %w", filepath.Base(localPath()), envName, err)
+		}
+		rekeyed.Environments[envName] = encrypted
+	}
+	return rekeyed, nil
+}
+
+// commitRekey writes the new env.toml, env.local.toml (if local is not nil), master.key and
+// .sse-recipients. Every file is written to a temporary path first and then renamed into place;
+// the old master.key is backed up and restored if env.toml cannot be replaced.
+func commitRekey(f, local *secrets.File, identities []*age.X25519Identity, l *recipients.List) error {
+	keyFile := keyPath()
+	secretsFile := secretsPath()
+	localFile := localPath()
+	recipientsFile := recipientsPath()
+
+	_, err := os.Stat(recipientsFile)
+	writeRecipients := err == nil
+
+	keyTmp := keyFile + ".new"
+	secretsTmp := secretsFile + ".new"
+	localTmp := localFile + ".new"
+	recipientsTmp := recipientsFile + ".new"
+	cleanup := func() {
+		os.Remove(keyTmp)
+		os.Remove(secretsTmp)
+		os.Remove(localTmp)
+		os.Remove(recipientsTmp)
+	}
+
+	// Keep the passphrase protection of the old key, if any
+	var passphrase string
+	if data, err := os.ReadFile(keyFile); err == nil && keyfile.IsProtected(data) {
+		p, err := keyfile.ReadPassphrase(fmt.Sprintf("Enter passphrase for %s: ", keyFile))
+		if err != nil {
+			return err
+		}
+		passphrase = p
+	}
+
+	if err := keyfile.WriteProtected(keyTmp, passphrase, identities...); err != nil {
+		cleanup()
+		return err
+	}
+	if err := f.Save(secretsTmp); err != nil {
+		cleanup()
+		return err
+	}
+	if local != nil {
+		if err := writePrivateFile(localTmp, local.Bytes()); err != nil {
+			cleanup()
+			return err
+		}
+	}
+	if writeRecipients {
+		if err := l.Save(recipientsTmp); err != nil {
+			cleanup()
+			return err
+		}
+	}
+
+	// Back up the old key before anything is replaced
+	var backupPath string
+	oldKey, err := os.ReadFile(keyFile)
+	if err == nil {
+		backupPath = fmt.Sprintf("%s.%s.bak", keyFile, time.Now().Format("20060102150405"))
+		if err := os.WriteFile(backupPath, oldKey, 0600); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to back up key file: %w", err)
+		}
+		fmt.Fprintf(os.Stderr, "Backed up old key to %s\n", backupPath)
+		if entry, ok := gitignoreEntry(keyFile); ok {
+			if err := addToGitignore(entry + ".*.bak"); err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: %v\n", err)
+			}
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		cleanup()
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+
+	if err := os.Rename(keyTmp, keyFile); err != nil {
+		cleanup()
+		return fmt.Errorf("failed to replace key file: %w", err)
+	}
+
+	if err := os.Rename(secretsTmp, secretsFile); err != nil {
+		// Put the old key back so env.toml stays readable
+		if oldKey != nil {
+			os.WriteFile(keyFile, oldKey, 0600)
+		} else {
+			os.Remove(keyFile)
+		}
+		cleanup()
+		return fmt.Errorf("failed to replace secrets file: %w", err)
+	}
+
+	if local != nil {
+		if err := os.Rename(localTmp, localFile); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to replace %s (its encrypted values need the old key): %w", localFile, err)
+		}
+	}
+
+	if writeRecipients {
+		if err := os.Rename(recipientsTmp, recipientsFile); err != nil {
+			cleanup()
+			return fmt.Errorf("failed to replace recipients file (update it with the new public key): %w", err)
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Re-encrypted %s with a new %s\n", secretsFile, keyFile)
+	return nil
+}
+
+func init() {
+	rekeyCmd.Flags().BoolVar(&rekeyKeepOld, "keep-old", false, "Keep the old identities in master.key for decryption")
+	rootCmd.AddCommand(rekeyCmd)
+}
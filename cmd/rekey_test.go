@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func TestRekey(t *testing.T) {
+	t.Run("re-encrypts local overrides to the new key", func(t *testing.T) {
+		newProject(t)
+		runSSE(t, "set", "API_KEY", "abc")
+		runSSE(t, "set", "--local", "DB", "x")
+		runSSE(t, "rekey")
+
+		out := runSSE(t, "load", "--shell", "posix")
+		for _, want := range []string{"export API_KEY='abc'", "export DB='x'"} {
+			if !strings.Contains(out, want) {
+				t.Errorf("load =\n%s\nwant it to contain %s", out, want)
+			}
+		}
+
+		info, err := os.Stat("env.local.toml")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if mode := info.Mode().Perm(); mode != 0600 {
+			t.Errorf("env.local.toml mode = %o, want 600", mode)
+		}
+	})
+	t.Run("names the configured recipients file", func(t *testing.T) {
+		newProject(t)
+		other, err := age.GenerateX25519Identity()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(".sse.toml", []byte("recipients = \"team.recipients\"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile("team.recipients", []byte(other.Recipient().String()+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = trySSE(t, "rekey")
+		if err == nil || !strings.Contains(err.Error(), "is not listed in") || !strings.Contains(err.Error(), "team.recipients") {
+			t.Errorf("rekey error = %v, want it to name team.recipients", err)
+		}
+	})
+}
@@ -3,12 +3,26 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"path/filepath"
+	"strconv"
 
+	"github.com/schrockwell/sse/internal/keyfile"
+	"github.com/schrockwell/sse/internal/project"
+	"github.com/schrockwell/sse/internal/secrets"
 	"github.com/spf13/cobra"
 )
 
 var Version = "0.1.3"
 
+// Global flags
+var (
+	secretsFile  string
+	keyFile      string
+	environment  string
+	identityFile string
+	strict       bool
+)
+
 var rootCmd = &cobra.Command{
 	Use:     "sse",
 	Version: Version,
@@ -27,7 +41,105 @@ The env.toml file contains sections for each environment:
   [production]
   API_KEY = "ENC[...]"
 
-Keys are human-readable, only values are encrypted.`,
+Keys are human-readable, only values are encrypted.
+
+Like git, sse looks for env.toml in the current directory and then in each
+parent directory, so commands work from anywhere inside a project. The
+directory that holds env.toml (or .sse.toml) is the project root, and
+master.key is read from there too.
+
+Instead of master.key, an SSH ed25519 or RSA private key can be used as
+the identity, via SSE_MASTER_KEY or --identity.
+
+Environment variables:
+  SSE_FILE        - path to env.toml (--file)
+  SSE_KEY_FILE    - path to master.key (--key)
+  SSE_ENV         - default environment (--env)
+  SSE_MASTER_KEY  - master key contents, used instead of the key file
+  SSE_STRICT      - fail on invalid key names instead of skipping them (--strict)
+
+Per-project defaults can be kept in .sse.toml at the project root; see
+"sse config --help". Flags win over environment variables, which win over
+.sse.toml, which wins over the built-in defaults.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadProjectConfig()
+	},
+}
+
+// rootDir caches the project root found by projectRoot.
+var rootDir string
+
+// projectRoot returns the nearest directory, starting from the working directory, that
+// contains env.toml or .sse.toml. The path is relative to the working directory. Without
+// a project, the working directory itself is used.
+func projectRoot() string {
+	if rootDir != "" {
+		return rootDir
+	}
+
+	rootDir = "."
+	if wd, err := os.Getwd(); err == nil {
+		if root, err := project.Find(wd); err == nil {
+			if rel, err := filepath.Rel(wd, root); err == nil {
+				rootDir = rel
+			}
+		}
+	}
+	return rootDir
+}
+
+// projectConfig holds the .sse.toml settings, loaded before every command runs.
+var projectConfig = &project.Config{}
+
+// loadProjectConfig reads .sse.toml from the project root.
+func loadProjectConfig() error {
+	c, err := project.LoadConfig(filepath.Join(projectRoot(), project.ConfigFile))
+	if err != nil {
+		return err
+	}
+	projectConfig = c
+	return nil
+}
+
+// secretsPath returns the path of env.toml from --file, SSE_FILE, .sse.toml, or the project root.
+func secretsPath() string {
+	path, _ := setting("file")
+	return path
+}
+
+// keyPath returns the path of master.key from --key, SSE_KEY_FILE, .sse.toml, or the project root.
+func keyPath() string {
+	path, _ := setting("key")
+	return path
+}
+
+// recipientsPath returns the path of .sse-recipients from .sse.toml, or next to env.toml.
+func recipientsPath() string {
+	path, _ := setting("recipients")
+	return path
+}
+
+// defaultEnvironment returns the environment to use from --env, SSE_ENV, .sse.toml, or the default.
+func defaultEnvironment() string {
+	name, _ := setting("env")
+	return name
+}
+
+// loadKey loads the key from --identity, SSE_MASTER_KEY or master.key, in that order.
+func loadKey() (*keyfile.Key, error) {
+	if identityFile != "" {
+		return keyfile.ReadKey(identityFile)
+	}
+	return keyfile.LoadKey(keyPath())
+}
+
+// strictMode reports whether invalid key names are an error, as set with --strict or SSE_STRICT.
+func strictMode() bool {
+	if strict {
+		return true
+	}
+	value, _ := strconv.ParseBool(os.Getenv(secrets.StrictEnvVar))
+	return value
 }
 
 func Execute() {
@@ -36,3 +148,11 @@ func Execute() {
 		os.Exit(1)
 	}
 }
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&secretsFile, "file", "", "Path to env.toml (default $SSE_FILE, .sse.toml or env.toml)")
+	rootCmd.PersistentFlags().StringVarP(&keyFile, "key", "k", "", "Path to master.key (default $SSE_KEY_FILE, .sse.toml or master.key)")
+	rootCmd.PersistentFlags().StringVarP(&environment, "env", "e", "", "Environment to use (default $SSE_ENV, .sse.toml or development)")
+	rootCmd.PersistentFlags().StringVarP(&identityFile, "identity", "i", "", "Identity file to use instead of master.key (age or SSH private key)")
+	rootCmd.PersistentFlags().BoolVar(&strict, "strict", false, "Fail on invalid key names instead of skipping them (default $SSE_STRICT)")
+}
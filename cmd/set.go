@@ -0,0 +1,137 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+var setLocal bool
+
+var setCmd = &cobra.Command{
+	Use:   "set KEY [VALUE]",
+	Short: "Encrypt and store a single value",
+	Long: `Encrypt a value and store it under KEY in env.toml.
+
+The value is taken from the VALUE argument if given. Otherwise it is read
+from stdin when stdin is not a terminal, or prompted for without echo.
+Only the given key is re-encrypted. The environment is created if needed.
+
+With --local, the value is stored in env.local.toml instead, encrypted to
+your own public key only.
+
+Examples:
+  sse set API_KEY                            # prompt for the value
+  sse set API_KEY abc123                     # development (default)
+  sse set -e production API_KEY abc123       # production
+  cat cert.pem | sse set -e production CERT  # read from stdin
+  sse set --local DATABASE_URL postgres://localhost/app  # personal override`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := defaultEnvironment()
+		key := args[0]
+		if err := secrets.ValidateKey(key); err != nil {
+			return err
+		}
+
+		var value string
+		if len(args) > 1 {
+			value = args[1]
+		} else {
+			v, err := readValue(key)
+			if err != nil {
+				return err
+			}
+			value = v
+		}
+
+		if setLocal {
+			return setLocalValue(envName, key, value)
+		}
+
+		recips, err := loadRecipients(envName)
+		if err != nil {
+			return err
+		}
+
+		f, err := secrets.Load(secretsPath())
+		if err != nil {
+			return err
+		}
+
+		encrypted, err := secrets.EncryptValue(value, recips...)
+		if err != nil {
+			return fmt.Errorf("failed to encrypt %s: %w", key, err)
+		}
+
+		f.Set(envName, key, encrypted)
+		if err := f.Save(secretsPath()); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Set %s in %s\n", key, envName)
+		return nil
+	},
+}
+
+// setLocalValue stores a value in env.local.toml, encrypted to the current identity.
+func setLocalValue(envName, key, value string) error {
+	k, err := loadKey()
+	if err != nil {
+		return err
+	}
+
+	local, err := loadLocalFile()
+	if err != nil {
+		return err
+	}
+	if local == nil {
+		local = &secrets.File{}
+	}
+
+	encrypted, err := secrets.EncryptValue(value, k.Recipient)
+	if err != nil {
+		return fmt.Errorf("failed to encrypt %s: %w", key, err)
+	}
+
+	local.Set(envName, key, encrypted)
+	if err := saveLocalFile(local); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "Set %s in %s (%s)\n", key, envName, localPath())
+	return nil
+}
+
+// readValue reads a value from stdin, or prompts for it without echo if stdin is a terminal.
+func readValue(key string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if term.IsTerminal(fd) {
+		fmt.Fprintf(os.Stderr, "%s: ", key)
+		data, err := term.ReadPassword(fd)
+		fmt.Fprintln(os.Stderr)
+		if err != nil {
+			return "", fmt.Errorf("failed to read value: %w", err)
+		}
+		return string(data), nil
+	}
+
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return "", fmt.Errorf("failed to read value from stdin: %w", err)
+	}
+	// Drop the single trailing newline added by echo and most editors
+	value := strings.TrimSuffix(string(data), "\n")
+	value = strings.TrimSuffix(value, "\r")
+	return value, nil
+}
+
+func init() {
+	setCmd.Flags().BoolVar(&setLocal, "local", false, "Store the value in env.local.toml, encrypted to your own key")
+	rootCmd.AddCommand(setCmd)
+}
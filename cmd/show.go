@@ -2,35 +2,58 @@ package cmd
 
 import (
 	"fmt"
+	"path/filepath"
 	"sort"
 
-	"github.com/schrockwell/sse/internal/keyfile"
 	"github.com/schrockwell/sse/internal/secrets"
 	"github.com/spf13/cobra"
 )
 
+var showResolved bool
+var showSources bool
+
 var showCmd = &cobra.Command{
 	Use:   "show",
 	Short: "Print decrypted env.toml",
-	Long: `Print the entire env.toml with all values decrypted.
+	Long: `Print the entire env.toml with all values decrypted and ${...}
+references expanded. Overrides from env.local.toml are merged in and marked.
+
+With --resolved, print the effective values of each environment instead,
+including those inherited from another environment or from [shared] and
+[default]. Inherited values are marked with the section they come from.
+
+With --sources, print the effective values like --resolved, marking every
+value with the file and section it comes from.
 
 Examples:
-  sse show`,
+  sse show
+  sse show --resolved
+  sse show --sources`,
 	Args: cobra.NoArgs,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		identity, err := keyfile.LoadIdentity()
+		key, err := loadKey()
 		if err != nil {
 			return err
 		}
 
-		f, err := secrets.Load(secrets.DefaultFile)
+		f, err := secrets.Load(secretsPath())
 		if err != nil {
 			return err
 		}
 
+		local, err := loadLocalFile()
+		if err != nil {
+			return err
+		}
+
+		resolved := showResolved || showSources
+
 		// Sort environment names
 		envNames := make([]string, 0, len(f.Environments))
 		for name := range f.Environments {
+			if resolved && secrets.IsBase(name) {
+				continue
+			}
 			envNames = append(envNames, name)
 		}
 		sort.Strings(envNames)
@@ -40,11 +63,32 @@ Examples:
 				fmt.Println()
 			}
 			fmt.Printf("[%s]\n", envName)
+			if parent := f.Inherits[envName]; parent != "" && !resolved {
+				fmt.Printf("%s = %q\n", secrets.InheritsKey, parent)
+			}
 
-			env := f.Environments[envName]
-			decrypted, err := secrets.DecryptEnvironment(env, identity)
+			_, sources, err := resolveEnvironment(f, local, key.Identities, envName)
+			if secrets.IsNoIdentityMatch(err) {
+				fmt.Println("# not shown: not a recipient of this environment")
+				continue
+			}
 			if err != nil {
-				return fmt.Errorf("failed to decrypt %s: %w", envName, err)
+				return err
+			}
+			decrypted, err := decryptEnvironment(f, local, key.Identities, envName)
+			if err != nil {
+				return fmt.Errorf("%s: %w", envName, err)
+			}
+
+			own := fmt.Sprintf("%s [%s]", filepath.Base(secretsPath()), envName)
+			localOwn := fmt.Sprintf("%s [%s]", filepath.Base(localPath()), envName)
+			if !resolved {
+				// Only the values set in this section or its local overrides
+				for k := range decrypted {
+					if _, ok := f.Environments[envName][k]; !ok && sources[k] != localOwn {
+						delete(decrypted, k)
+					}
+				}
 			}
 
 			// Sort keys
@@ -55,7 +99,12 @@ Examples:
 			sort.Strings(keys)
 
 			for _, key := range keys {
-				fmt.Printf("%s = %q\n", key, decrypted[key])
+				value := formatValue(f, local, envName, key, decrypted[key], sources[key])
+				if source := sources[key]; showSources || source != own {
+					fmt.Printf("%s = %s  # from %s\n", secrets.QuoteKey(key), value, source)
+				} else {
+					fmt.Printf("%s = %s\n", secrets.QuoteKey(key), value)
+				}
 			}
 		}
 
@@ -63,6 +112,27 @@ Examples:
 	},
 }
 
+// formatValue returns a value as TOML, keeping non-string values as written in the section
+// they come from, so that the output can be pasted back into env.toml.
+func formatValue(f, local *secrets.File, envName, key, value, source string) string {
+	layers, localLayers, err := environmentLayers(f, envName)
+	if err == nil {
+		for _, layer := range layers {
+			if source == fmt.Sprintf("%s [%s]", filepath.Base(secretsPath()), layer) {
+				return f.FormatValue(layer, key, value)
+			}
+		}
+		for _, layer := range localLayers {
+			if local != nil && source == fmt.Sprintf("%s [%s]", filepath.Base(localPath()), layer) {
+				return local.FormatValue(layer, key, value)
+			}
+		}
+	}
+	return f.FormatValue("", key, value)
+}
+
 func init() {
+	showCmd.Flags().BoolVar(&showSources, "sources", false, "Show the effective values of each environment and where each one comes from")
+	showCmd.Flags().BoolVar(&showResolved, "resolved", false, "Show the effective values of each environment, including inherited ones")
 	rootCmd.AddCommand(showCmd)
 }
@@ -0,0 +1,45 @@
+package cmd
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestShow(t *testing.T) {
+	t.Run("prints non-string values as written", func(t *testing.T) {
+		newProject(t)
+		data := "[shared]\nHOSTS = [\"a\", \"b\"]\n\n[development]\nPORT = 3000\nDEBUG = true\n"
+		if err := os.WriteFile("env.toml", []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+		runSSE(t, "set", "NAME", "it's \"app\"")
+
+		out := runSSE(t, "show")
+		for _, want := range []string{`HOSTS = ["a", "b"]`, "PORT = 3000", "DEBUG = true", `NAME = "it's \"app\""`} {
+			if !strings.Contains(out, want+"\n") {
+				t.Errorf("show =\n%s\nwant it to contain %s", out, want)
+			}
+		}
+
+		resolved := runSSE(t, "show", "--resolved")
+		if !strings.Contains(resolved, `HOSTS = ["a", "b"]  # from env.toml [shared]`) {
+			t.Errorf("show --resolved =\n%s", resolved)
+		}
+	})
+
+	t.Run("quotes keys that are not bare TOML keys", func(t *testing.T) {
+		newProject(t)
+		data := "[development]\n\"A=B\" = \"x#y\"\n\"QUOTED.KEY\" = 7\n"
+		if err := os.WriteFile("env.toml", []byte(data), 0644); err != nil {
+			t.Fatal(err)
+		}
+
+		out := runSSE(t, "show")
+		for _, want := range []string{`"A=B" = "x#y"`, `"QUOTED.KEY" = 7`} {
+			if !strings.Contains(out, want+"\n") {
+				t.Errorf("show =\n%s\nwant it to contain %s", out, want)
+			}
+		}
+	})
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/spf13/cobra"
+)
+
+var unsetLocal bool
+
+var unsetCmd = &cobra.Command{
+	Use:   "unset KEY",
+	Short: "Remove a single value",
+	Long: `Remove KEY from an environment in env.toml, or in env.local.toml with --local.
+
+Examples:
+  sse unset API_KEY                  # development (default)
+  sse unset -e production API_KEY    # production
+  sse unset --local DATABASE_URL     # remove a personal override`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		envName := defaultEnvironment()
+		key := args[0]
+
+		if unsetLocal {
+			local, err := loadLocalFile()
+			if err != nil {
+				return err
+			}
+			if local == nil || !local.Unset(envName, key) {
+				return fmt.Errorf("key %q not found in %s of %s", key, envName, localPath())
+			}
+			if err := saveLocalFile(local); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "Unset %s in %s (%s)\n", key, envName, localPath())
+			return nil
+		}
+
+		f, err := secrets.Load(secretsPath())
+		if err != nil {
+			return err
+		}
+
+		if _, err := f.GetEnvironment(envName); err != nil {
+			return err
+		}
+
+		if !f.Unset(envName, key) {
+			return fmt.Errorf("key %q not found in %s", key, envName)
+		}
+
+		if err := f.Save(secretsPath()); err != nil {
+			return err
+		}
+
+		fmt.Fprintf(os.Stderr, "Unset %s in %s\n", key, envName)
+		return nil
+	},
+}
+
+func init() {
+	unsetCmd.Flags().BoolVar(&unsetLocal, "local", false, "Remove the value from env.local.toml")
+	rootCmd.AddCommand(unsetCmd)
+}
@@ -4,14 +4,39 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"os/signal"
+	"reflect"
+	"strings"
 	"syscall"
+	"time"
 
-	"github.com/schrockwell/sse/internal/keyfile"
+	"github.com/schrockwell/sse/internal/environ"
+	"github.com/schrockwell/sse/internal/redact"
 	"github.com/schrockwell/sse/internal/secrets"
+	"github.com/schrockwell/sse/internal/supervise"
 	"github.com/spf13/cobra"
 )
 
-var withEnv string
+var (
+	withSupervise bool
+	withTimeout   time.Duration
+
+	withRedact          bool
+	withRedactMinLength int
+
+	withClean      bool
+	withKeep       []string
+	withNoOverride bool
+	withOnly       []string
+	withExcept     []string
+	withPrefix     string
+	withDryRun     bool
+
+	withWatch         bool
+	withWatchInterval time.Duration
+	withStopSignal    string
+	withGrace         time.Duration
+)
 
 var withCmd = &cobra.Command{
 	Use:   "with [environment] -- <command> [args...]",
@@ -19,64 +44,295 @@ var withCmd = &cobra.Command{
 	Long: `Decrypt secrets for the specified environment and run a command
 with those environment variables. Decrypted values are never written to disk.
 
+The environment is the argument before --, or else the one chosen with
+--env, SSE_ENV or .sse.toml (development by default). Everything after --
+is the command, so a command is never mistaken for an environment. An
+unknown environment is an error.
+
+By default sse replaces itself with the command. With --supervise, the
+command runs as a child process instead: SIGINT, SIGTERM and SIGHUP are
+passed on to it, and sse exits with its exit code, or by the same signal.
+With --timeout, the command is sent the --stop-signal (SIGTERM) once it
+has run that long, killed if it has not exited --grace (10s) later, and
+sse exits with 124.
+
+With --redact, every decrypted value in the output of the command is
+replaced with ***KEY***, where KEY is the name of the value. Values shorter
+than --redact-min-length are left alone. This implies --supervise; the
+output of the command is piped through sse, so it is not a terminal.
+
+Secrets are added to the current environment and win over variables that
+are already set, unless --no-override is given. --clean starts from an
+empty environment instead, keeping only the variables named with --keep.
+--only and --except choose which secrets are added, and --prefix renames
+them. Every variable is passed once. --dry-run prints the result, with
+secret values hidden, instead of running the command.
+
+With --watch, env.toml, env.local.toml and master.key are checked every
+--watch-interval. When a decrypted value changes, the command is stopped
+with --stop-signal and --grace and started again with the new values.
+Changes that only re-encrypt values do not restart it. sse exits once the
+command exits on its own.
+
 Examples:
-  sse with -- env                     # use development (default)
-  sse with -- npm start               # run npm with secrets
-  sse with production -- ./deploy.sh  # use production secrets`,
+  sse with -- env                        # use development (default)
+  sse with -- npm start                  # run npm with secrets
+  sse with production -- ./deploy.sh     # use production secrets
+  sse with -e production -- ./deploy.sh  # the same
+  sse with --timeout 10m -- make test    # give up after 10 minutes
+  sse with --redact -- npm test          # mask secrets in CI logs
+  sse with --clean --keep PATH,HOME --only DATABASE_URL -- ./migrate
+  sse with --prefix APP_ --dry-run -- ./server
+  sse with --watch -- npm run dev        # restart when secrets change
+  sse with --watch --stop-signal INT --grace 30s -- ./server`,
 	DisableFlagsInUseLine: true,
 	Args:                  cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		envName := secrets.DefaultEnvironment
+		envName := defaultEnvironment()
 		cmdArgs := args
 
-		// Check if first arg looks like an environment name (not a command)
-		// by seeing if it exists in env.toml
-		if len(args) > 1 {
-			f, err := secrets.Load(secrets.DefaultFile)
-			if err != nil {
-				return err
-			}
-			if _, exists := f.Environments[args[0]]; exists {
+		// Only an argument before -- can name the environment
+		if dash := cmd.ArgsLenAtDash(); dash >= 0 {
+			switch {
+			case dash > 1:
+				return fmt.Errorf("expected at most one environment before --, got %d arguments", dash)
+			case dash == 1 && cmd.Flags().Changed("env") && args[0] != environment:
+				return fmt.Errorf("environment %q conflicts with --env %q", args[0], environment)
+			case dash == 1:
 				envName = args[0]
-				cmdArgs = args[1:]
 			}
+			cmdArgs = args[dash:]
+		}
+		if len(cmdArgs) == 0 {
+			return fmt.Errorf("no command given after --")
 		}
 
-		identity, err := keyfile.LoadIdentity()
+		vars, all, err := withEnvironment(envName)
 		if err != nil {
 			return err
 		}
 
-		f, err := secrets.Load(secrets.DefaultFile)
+		// Find the command
+		binary, err := exec.LookPath(cmdArgs[0])
 		if err != nil {
-			return err
+			return fmt.Errorf("command not found: %s", cmdArgs[0])
 		}
 
-		env, err := f.GetEnvironment(envName)
-		if err != nil {
-			return err
+		if withDryRun {
+			printDryRun(vars, envName, cmdArgs)
+			return nil
 		}
 
-		decrypted, err := secrets.DecryptEnvironment(env, identity)
+		stopSignal, err := supervise.ParseSignal(withStopSignal)
 		if err != nil {
-			return fmt.Errorf("failed to decrypt: %w", err)
+			return err
 		}
 
-		// Build environment: current env + secrets
-		environ := os.Environ()
-		environ = append(environ, secrets.ToEnvList(decrypted)...)
-
-		// Find the command
-		binary, err := exec.LookPath(cmdArgs[0])
-		if err != nil {
-			return fmt.Errorf("command not found: %s", cmdArgs[0])
+		if withWatch {
+			if withTimeout > 0 {
+				return fmt.Errorf("--timeout cannot be used with --watch")
+			}
+			if withWatchInterval <= 0 {
+				return fmt.Errorf("--watch-interval must be positive")
+			}
+			return runWatched(binary, cmdArgs, envName, vars, all, stopSignal)
+		}
+		if withSupervise || withRedact || withTimeout > 0 {
+			return runSupervised(binary, cmdArgs, vars, all, stopSignal)
 		}
 
 		// Replace current process with the command
-		return syscall.Exec(binary, cmdArgs, environ)
+		return syscall.Exec(binary, cmdArgs, environ.List(vars))
 	},
 }
 
+// withEnvironment decrypts an environment and builds the environment of the command from it.
+// It also returns every decrypted value, for redaction.
+func withEnvironment(envName string) ([]environ.Var, map[string]string, error) {
+	key, err := loadKey()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	f, err := secrets.Load(secretsPath())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	local, err := loadLocalFile()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	all, err := decryptEnvironment(f, local, key.Identities, envName)
+	if err != nil {
+		return nil, nil, err
+	}
+	decrypted, err := exportableEnvironment(all, envName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Build environment: current env + secrets, one entry per name
+	vars, err := environ.Build(os.Environ(), decrypted, environ.Options{
+		Clean:      withClean,
+		Keep:       withKeep,
+		NoOverride: withNoOverride,
+		Only:       withOnly,
+		Except:     withExcept,
+		Prefix:     withPrefix,
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to build the environment for %s: %w", envName, err)
+	}
+	return vars, all, nil
+}
+
+// printDryRun prints the environment the command would get, with secret values hidden.
+func printDryRun(vars []environ.Var, envName string, args []string) {
+	secretCount := 0
+	for _, v := range vars {
+		if v.Key == "" {
+			fmt.Printf("%s=%s\n", v.Name, v.Value)
+			continue
+		}
+		secretCount++
+		if v.Key == v.Name {
+			fmt.Printf("%s=***  # %s\n", v.Name, envName)
+		} else {
+			fmt.Printf("%s=***  # %s %s\n", v.Name, envName, v.Key)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "Would run %s with %d variables, %d from %s\n", strings.Join(args, " "), len(vars), secretCount, envName)
+}
+
+// newChild returns the command to run as a child process. With --redact, its output is masked,
+// and the returned function writes what the filters hold back once the child has exited.
+func newChild(binary string, args []string, vars []environ.Var, values map[string]string) (*exec.Cmd, func()) {
+	child := exec.Command(binary, args[1:]...)
+	child.Args[0] = args[0]
+	child.Env = environ.List(vars)
+	child.Stdin = os.Stdin
+	child.Stdout = os.Stdout
+	child.Stderr = os.Stderr
+
+	if !withRedact {
+		return child, func() {}
+	}
+	stdout := redact.NewWriter(os.Stdout, values, withRedactMinLength)
+	stderr := redact.NewWriter(os.Stderr, values, withRedactMinLength)
+	child.Stdout = stdout
+	child.Stderr = stderr
+	return child, func() {
+		stdout.Flush()
+		stderr.Flush()
+	}
+}
+
+// runSupervised runs the command as a child process and exits the way it did.
+func runSupervised(binary string, args []string, vars []environ.Var, values map[string]string, stopSignal syscall.Signal) error {
+	child, flush := newChild(binary, args, vars, values)
+	status, err := supervise.Run(child, withTimeout, stopSignal, withGrace)
+	flush()
+	if err != nil {
+		return err
+	}
+	if status.TimedOut {
+		fmt.Fprintf(os.Stderr, "%s timed out after %s\n", args[0], withTimeout)
+	}
+	supervise.Exit(status)
+	return nil
+}
+
+// runWatched runs the command as a child process and restarts it whenever the decrypted
+// environment changes. It exits the way the child did once the child exits on its own.
+func runWatched(binary string, args []string, envName string, vars []environ.Var, values map[string]string, stopSignal syscall.Signal) error {
+	key := keyPath()
+	if identityFile != "" {
+		key = identityFile
+	}
+	poller := supervise.NewPoller(secretsPath(), localPath(), key)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, supervise.Forwarded...)
+	defer signal.Stop(signals)
+
+	child, flush := newChild(binary, args, vars, values)
+	p, err := supervise.Start(child)
+	if err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(withWatchInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case sig := <-signals:
+			p.Signal(sig)
+
+		case <-p.Done():
+			status, err := p.Wait()
+			flush()
+			if err != nil {
+				return err
+			}
+			supervise.Exit(status)
+
+		case <-ticker.C:
+			if !poller.Changed() {
+				continue
+			}
+			newVars, newValues, err := withEnvironment(envName)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Warning: not restarting %s: %v\n", args[0], err)
+				continue
+			}
+			if !restartNeeded(vars, newVars, values, newValues, withRedact) {
+				continue
+			}
+
+			fmt.Fprintf(os.Stderr, "%s changed, restarting %s\n", envName, args[0])
+			if _, err := p.Stop(stopSignal, withGrace); err != nil {
+				return err
+			}
+			flush()
+
+			vars, values = newVars, newValues
+			child, flush = newChild(binary, args, vars, values)
+			if p, err = supervise.Start(child); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// restartNeeded reports whether a watched command has to be restarted for a newly decrypted
+// environment. Only the decrypted values count, so re-encrypting a value changes nothing. When
+// redacting, a change to any value counts, even one that is not passed to the command, as it
+// changes what is masked.
+func restartNeeded(oldVars, newVars []environ.Var, oldValues, newValues map[string]string, redacting bool) bool {
+	if !reflect.DeepEqual(environ.List(oldVars), environ.List(newVars)) {
+		return true
+	}
+	return redacting && !reflect.DeepEqual(oldValues, newValues)
+}
+
 func init() {
+	withCmd.Flags().BoolVar(&withSupervise, "supervise", false, "Run the command as a child process instead of replacing sse")
+	withCmd.Flags().DurationVar(&withTimeout, "timeout", 0, "Stop the command after this long, e.g. 30s or 5m (implies --supervise)")
+	withCmd.Flags().BoolVar(&withRedact, "redact", false, "Mask decrypted values in the output of the command (implies --supervise)")
+	withCmd.Flags().IntVar(&withRedactMinLength, "redact-min-length", redact.DefaultMinLength, "Do not mask values shorter than this")
+	withCmd.Flags().BoolVar(&withClean, "clean", false, "Start from an empty environment instead of the current one")
+	withCmd.Flags().StringSliceVar(&withKeep, "keep", nil, "Variables of the current environment to keep with --clean, e.g. PATH,HOME")
+	withCmd.Flags().BoolVar(&withNoOverride, "no-override", false, "Let variables that are already set win over secrets")
+	withCmd.Flags().StringSliceVar(&withOnly, "only", nil, "Add only these secrets, e.g. DATABASE_URL,REDIS_URL")
+	withCmd.Flags().StringSliceVar(&withExcept, "except", nil, "Add all secrets except these")
+	withCmd.Flags().StringVar(&withPrefix, "prefix", "", "Prepend this to the name of every secret, e.g. APP_")
+	withCmd.Flags().BoolVar(&withDryRun, "dry-run", false, "Print the environment the command would get, with secrets hidden, instead of running it")
+	withCmd.Flags().BoolVar(&withWatch, "watch", false, "Restart the command when decrypted values change (implies --supervise)")
+	withCmd.Flags().DurationVar(&withWatchInterval, "watch-interval", time.Second, "How often to check for changes with --watch")
+	withCmd.Flags().StringVar(&withStopSignal, "stop-signal", "TERM", "Signal that stops the command on --timeout or a --watch restart")
+	withCmd.Flags().DurationVar(&withGrace, "grace", supervise.DefaultGrace, "How long the command may take to stop before it is killed")
 	rootCmd.AddCommand(withCmd)
 }
@@ -0,0 +1,56 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/schrockwell/sse/internal/environ"
+)
+
+func TestRestartNeeded(t *testing.T) {
+	newProject(t)
+	runSSE(t, "set", "API_KEY", "abc")
+	runSSE(t, "set", "OTHER", "one")
+
+	load := func() ([]environ.Var, map[string]string) {
+		t.Helper()
+		vars, values, err := withEnvironment("development")
+		if err != nil {
+			t.Fatalf("withEnvironment() error = %v", err)
+		}
+		return vars, values
+	}
+	vars, values := load()
+
+	t.Run("not for re-encrypted values", func(t *testing.T) {
+		runSSE(t, "set", "API_KEY", "abc")
+		newVars, newValues := load()
+		if restartNeeded(vars, newVars, values, newValues, true) {
+			t.Error("restartNeeded() = true after re-encrypting the same value")
+		}
+	})
+
+	t.Run("for a changed value", func(t *testing.T) {
+		runSSE(t, "set", "API_KEY", "def")
+		newVars, newValues := load()
+		if !restartNeeded(vars, newVars, values, newValues, false) {
+			t.Error("restartNeeded() = false after a value changed")
+		}
+		vars, values = newVars, newValues
+	})
+
+	t.Run("for a filtered value only when redacting", func(t *testing.T) {
+		withExcept = []string{"OTHER"}
+		defer func() { withExcept = nil }()
+		vars, values := load()
+
+		runSSE(t, "set", "OTHER", "two")
+		withExcept = []string{"OTHER"}
+		newVars, newValues := load()
+		if restartNeeded(vars, newVars, values, newValues, false) {
+			t.Error("restartNeeded() = true for a value that is not passed on")
+		}
+		if !restartNeeded(vars, newVars, values, newValues, true) {
+			t.Error("restartNeeded() = false for a changed value that is redacted")
+		}
+	})
+}
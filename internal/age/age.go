@@ -10,13 +10,13 @@ import (
 	"filippo.io/age/armor"
 )
 
-// Encrypt encrypts plaintext using the given recipient and returns armored ciphertext.
-func Encrypt(plaintext []byte, recipient age.Recipient) ([]byte, error) {
+// Encrypt encrypts plaintext to the given recipients and returns armored ciphertext.
+func Encrypt(plaintext []byte, recipients ...age.Recipient) ([]byte, error) {
 	var buf bytes.Buffer
 
 	armorWriter := armor.NewWriter(&buf)
 
-	w, err := age.Encrypt(armorWriter, recipient)
+	w, err := age.Encrypt(armorWriter, recipients...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create encryption writer: %w", err)
 	}
@@ -36,11 +36,11 @@ func Encrypt(plaintext []byte, recipient age.Recipient) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-// Decrypt decrypts armored ciphertext using the given identity.
-func Decrypt(ciphertext []byte, identity age.Identity) ([]byte, error) {
+// Decrypt decrypts armored ciphertext using whichever of the given identities matches.
+func Decrypt(ciphertext []byte, identities ...age.Identity) ([]byte, error) {
 	armorReader := armor.NewReader(bytes.NewReader(ciphertext))
 
-	r, err := age.Decrypt(armorReader, identity)
+	r, err := age.Decrypt(armorReader, identities...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create decryption reader: %w", err)
 	}
@@ -80,6 +80,26 @@ func TestEncryptDecrypt(t *testing.T) {
 		}
 	})
 
+	t.Run("encrypts to multiple recipients", func(t *testing.T) {
+		plaintext := []byte("shared secret")
+		other := generateTestIdentity(t)
+
+		ciphertext, err := Encrypt(plaintext, recipient, other.Recipient())
+		if err != nil {
+			t.Fatalf("Encrypt() error = %v", err)
+		}
+
+		for _, id := range []age.Identity{identity, other} {
+			decrypted, err := Decrypt(ciphertext, id)
+			if err != nil {
+				t.Fatalf("Decrypt() error = %v", err)
+			}
+			if !bytes.Equal(decrypted, plaintext) {
+				t.Errorf("decrypted = %q, want %q", decrypted, plaintext)
+			}
+		}
+	})
+
 	t.Run("handles unicode", func(t *testing.T) {
 		plaintext := []byte("こんにちは世界 🌍")
 
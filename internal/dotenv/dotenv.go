@@ -0,0 +1,200 @@
+package dotenv
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// Entry is a single KEY=value assignment.
+type Entry struct {
+	Key   string
+	Value string
+}
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_.-]*$`)
+
+// Parse parses dotenv syntax and returns the assignments in the order they appear.
+//
+// Lines may start with "export". Unquoted values end at a " #" comment and are trimmed.
+// Single-quoted values are taken literally. Double-quoted values support the escapes
+// \n, \r, \t, \", \\ and \$. Quoted values may span multiple lines.
+func Parse(data []byte) ([]Entry, error) {
+	p := &parser{data: strings.ReplaceAll(string(data), "\r\n", "\n"), line: 1}
+
+	var entries []Entry
+	for {
+		p.skipBlank()
+		if p.eof() {
+			return entries, nil
+		}
+
+		entry, err := p.entry()
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", p.line, err)
+		}
+		entries = append(entries, entry)
+	}
+}
+
+type parser struct {
+	data string
+	pos  int
+	line int
+}
+
+func (p *parser) eof() bool {
+	return p.pos >= len(p.data)
+}
+
+// skipBlank skips whitespace, blank lines and comment lines.
+func (p *parser) skipBlank() {
+	for !p.eof() {
+		switch c := p.data[p.pos]; {
+		case c == '\n':
+			p.line++
+			p.pos++
+		case c == ' ' || c == '\t':
+			p.pos++
+		case c == '#':
+			p.skipLine()
+		default:
+			return
+		}
+	}
+}
+
+// skipLine moves past the end of the current line.
+func (p *parser) skipLine() {
+	for !p.eof() && p.data[p.pos] != '\n' {
+		p.pos++
+	}
+}
+
+// restOfLine returns the rest of the current line without consuming the newline.
+func (p *parser) restOfLine() string {
+	start := p.pos
+	p.skipLine()
+	return p.data[start:p.pos]
+}
+
+func (p *parser) entry() (Entry, error) {
+	line := p.data[p.pos:]
+	if end := strings.IndexByte(line, '\n'); end >= 0 {
+		line = line[:end]
+	}
+
+	eq := strings.IndexByte(line, '=')
+	if eq < 0 {
+		return Entry{}, fmt.Errorf("expected KEY=value, found %q", strings.TrimSpace(line))
+	}
+
+	key := strings.TrimSpace(line[:eq])
+	if rest, ok := strings.CutPrefix(key, "export"); ok && rest != "" && (rest[0] == ' ' || rest[0] == '\t') {
+		key = strings.TrimSpace(rest)
+	}
+	if !keyPattern.MatchString(key) {
+		return Entry{}, fmt.Errorf("invalid key %q", key)
+	}
+
+	p.pos += eq + 1
+	for !p.eof() && (p.data[p.pos] == ' ' || p.data[p.pos] == '\t') {
+		p.pos++
+	}
+
+	value, err := p.value()
+	if err != nil {
+		return Entry{}, fmt.Errorf("%s: %w", key, err)
+	}
+	return Entry{Key: key, Value: value}, nil
+}
+
+func (p *parser) value() (string, error) {
+	if p.eof() {
+		return "", nil
+	}
+
+	switch quote := p.data[p.pos]; quote {
+	case '\'', '"':
+		p.pos++
+		var b strings.Builder
+		for {
+			if p.eof() {
+				return "", fmt.Errorf("unterminated %c-quoted value", quote)
+			}
+			c := p.data[p.pos]
+			p.pos++
+
+			switch {
+			case c == quote:
+				// Only a comment may follow the closing quote
+				if rest := strings.TrimSpace(p.restOfLine()); rest != "" && !strings.HasPrefix(rest, "#") {
+					return "", fmt.Errorf("unexpected %q after closing quote", rest)
+				}
+				return b.String(), nil
+			case c == '\n':
+				p.line++
+				b.WriteByte(c)
+			case c == '\\' && quote == '"' && !p.eof():
+				next := p.data[p.pos]
+				p.pos++
+				switch next {
+				case 'n':
+					b.WriteByte('\n')
+				case 'r':
+					b.WriteByte('\r')
+				case 't':
+					b.WriteByte('\t')
+				case '"', '\\', '$':
+					b.WriteByte(next)
+				default:
+					// Unknown escapes are kept as written
+					b.WriteByte('\\')
+					b.WriteByte(next)
+				}
+			default:
+				b.WriteByte(c)
+			}
+		}
+
+	default:
+		value := p.restOfLine()
+		// A comment starts at a # that begins the value or follows whitespace
+		for i := 0; i < len(value); i++ {
+			if value[i] == '#' && (i == 0 || value[i-1] == ' ' || value[i-1] == '\t') {
+				value = value[:i]
+				break
+			}
+		}
+		return strings.TrimSpace(value), nil
+	}
+}
+
+// Quote returns value as a quoted string that Parse reads back unchanged. Values with $, \
+// or " are single-quoted when they have no ', since single quotes are taken literally by
+// every dotenv reader. Other values are double-quoted, escaping line breaks as \n.
+func Quote(value string) string {
+	if strings.ContainsAny(value, `$\"`) && !strings.Contains(value, "'") {
+		return "'" + value + "'"
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch r {
+		case '\\', '"', '$':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
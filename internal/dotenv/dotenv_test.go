@@ -0,0 +1,136 @@
+package dotenv
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("parses dotenv syntax", func(t *testing.T) {
+		data := `# Database
+DB_HOST=localhost
+export DB_USER = app
+DB_PASSWORD='pa$$ "word"'   # single quotes are literal
+GREETING="hello\n\"world\" \$HOME"
+EMPTY=
+URL=https://example.com/#anchor # comment
+CERT="-----BEGIN CERT-----
+abc
+-----END CERT-----"
+RAW='line one
+line two'
+`
+		entries, err := Parse([]byte(data))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+
+		want := []Entry{
+			{"DB_HOST", "localhost"},
+			{"DB_USER", "app"},
+			{"DB_PASSWORD", `pa$$ "word"`},
+			{"GREETING", "hello\n\"world\" $HOME"},
+			{"EMPTY", ""},
+			{"URL", "https://example.com/#anchor"},
+			{"CERT", "-----BEGIN CERT-----\nabc\n-----END CERT-----"},
+			{"RAW", "line one\nline two"},
+		}
+		if !reflect.DeepEqual(entries, want) {
+			t.Errorf("Parse() =\n%q\nwant\n%q", entries, want)
+		}
+	})
+
+	t.Run("handles CRLF line endings", func(t *testing.T) {
+		entries, err := Parse([]byte("A=1\r\nB=\"2\"\r\n"))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if want := []Entry{{"A", "1"}, {"B", "2"}}; !reflect.DeepEqual(entries, want) {
+			t.Errorf("Parse() = %q, want %q", entries, want)
+		}
+	})
+
+	t.Run("keeps a key named export", func(t *testing.T) {
+		entries, err := Parse([]byte("export=1\n"))
+		if err != nil {
+			t.Fatalf("Parse() error = %v", err)
+		}
+		if want := []Entry{{"export", "1"}}; !reflect.DeepEqual(entries, want) {
+			t.Errorf("Parse() = %q, want %q", entries, want)
+		}
+	})
+
+	t.Run("strips comments from unquoted values", func(t *testing.T) {
+		// A # that begins an unquoted value starts a comment, even with
+		// no space after the =, so KEY=#c is empty. Quote the value to
+		// keep a leading #.
+		tests := map[string]string{
+			"KEY= # c\n":       "",
+			"KEY=#c\n":         "",
+			"KEY=\"#c\"\n":     "#c",
+			"KEY=a#b # c\n":    "a#b",
+			"KEY=a\t# c\n":     "a",
+			"KEY=  spaced  \n": "spaced",
+		}
+		for data, want := range tests {
+			entries, err := Parse([]byte(data))
+			if err != nil {
+				t.Fatalf("Parse(%q) error = %v", data, err)
+			}
+			if len(entries) != 1 || entries[0].Value != want {
+				t.Errorf("Parse(%q) = %q, want value %q", data, entries, want)
+			}
+		}
+	})
+
+	t.Run("reports errors with line numbers", func(t *testing.T) {
+		tests := map[string]struct {
+			data string
+			want string
+		}{
+			"missing equals": {"A=1\nnot an assignment\n", "line 2"},
+			"invalid key":    {"1KEY=x\n", "invalid key"},
+			"unterminated":   {"A=\"open\nB=2\n", "unterminated"},
+			"trailing text":  {"A=\"x\" y\n", "after closing quote"},
+		}
+		for name, tt := range tests {
+			_, err := Parse([]byte(tt.data))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("%s: Parse() error = %v, want %q", name, err, tt.want)
+			}
+		}
+	})
+}
+
+func TestQuote(t *testing.T) {
+	t.Run("single-quotes values with $ or backslashes", func(t *testing.T) {
+		tests := map[string]string{
+			"pa$$word":        `'pa$$word'`,
+			`C:\path`:         `'C:\path'`,
+			`say "hi"`:        `'say "hi"'`,
+			"it's $5":         `"it's \$5"`,
+			"plain":           `"plain"`,
+			"two\nlines":      `"two\nlines"`,
+			"${HOME}\nsecond": "'${HOME}\nsecond'",
+		}
+		for value, want := range tests {
+			if got := Quote(value); got != want {
+				t.Errorf("Quote(%q) = %s, want %s", value, got, want)
+			}
+		}
+	})
+
+	t.Run("round-trips through Parse", func(t *testing.T) {
+		values := []string{"", "plain", "it's \"quoted\"", "multi\nline\r\n", "tab\there", `back\slash`, "$HOME ${X}", "it's $5", "# not a comment"}
+		for _, value := range values {
+			entries, err := Parse([]byte("KEY=" + Quote(value) + "\n"))
+			if err != nil {
+				t.Fatalf("Parse(Quote(%q)) error = %v", value, err)
+			}
+			if len(entries) != 1 || entries[0].Value != value {
+				t.Errorf("Parse(Quote(%q)) = %q", value, entries)
+			}
+		}
+	})
+}
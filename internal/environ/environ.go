@@ -0,0 +1,122 @@
+package environ
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/schrockwell/sse/internal/secrets"
+)
+
+// Options control how secrets are combined with the environment of the current process.
+type Options struct {
+	// Clean starts from an empty environment instead of the current one,
+	// except for the variables named in Keep.
+	Clean bool
+	Keep  []string
+	// NoOverride lets variables that are already set win over secrets.
+	NoOverride bool
+	// Only and Except select the secrets to add by key.
+	Only   []string
+	Except []string
+	// Prefix is prepended to the name of every secret.
+	Prefix string
+}
+
+// Var is a variable of the resulting environment.
+type Var struct {
+	Name  string
+	Value string
+	// Key is the key of the secret the value comes from, or empty if it comes from the process.
+	Key string
+}
+
+// Build combines the process environment, as KEY=value strings, with the given secrets.
+// Every name appears once, and the result is sorted by name.
+func Build(base []string, values map[string]string, opts Options) ([]Var, error) {
+	if len(opts.Keep) > 0 && !opts.Clean {
+		return nil, fmt.Errorf("--keep requires --clean")
+	}
+
+	vars := make(map[string]Var)
+
+	keep := make(map[string]bool)
+	for _, name := range opts.Keep {
+		keep[name] = true
+	}
+	// Later entries win, as they do for the exec package
+	for _, kv := range base {
+		name, value, ok := strings.Cut(kv, "=")
+		if !ok || (opts.Clean && !keep[name]) {
+			continue
+		}
+		vars[name] = Var{Name: name, Value: value}
+	}
+
+	selected, err := selectKeys(values, opts.Only, opts.Except)
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range selected {
+		name := opts.Prefix + key
+		if err := secrets.ValidateKey(name); err != nil {
+			return nil, fmt.Errorf("invalid prefix %q: %w", opts.Prefix, err)
+		}
+		if _, set := vars[name]; set && opts.NoOverride {
+			continue
+		}
+		vars[name] = Var{Name: name, Value: values[key], Key: key}
+	}
+
+	result := make([]Var, 0, len(vars))
+	for _, v := range vars {
+		result = append(result, v)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+	return result, nil
+}
+
+// selectKeys returns the sorted keys of values, limited to only if it is not empty and
+// without the ones in except. Every key in only must be set.
+func selectKeys(values map[string]string, only, except []string) ([]string, error) {
+	var keys []string
+	if len(only) > 0 {
+		for _, key := range only {
+			if _, ok := values[key]; !ok {
+				return nil, fmt.Errorf("%s is not set", key)
+			}
+			keys = append(keys, key)
+		}
+	} else {
+		for key := range values {
+			keys = append(keys, key)
+		}
+	}
+
+	excluded := make(map[string]bool)
+	for _, key := range except {
+		excluded[key] = true
+	}
+
+	seen := make(map[string]bool)
+	result := keys[:0]
+	for _, key := range keys {
+		if !excluded[key] && !seen[key] {
+			seen[key] = true
+			result = append(result, key)
+		}
+	}
+	sort.Strings(result)
+	return result, nil
+}
+
+// List returns the variables as KEY=value strings.
+func List(vars []Var) []string {
+	result := make([]string, len(vars))
+	for i, v := range vars {
+		result[i] = v.Name + "=" + v.Value
+	}
+	return result
+}
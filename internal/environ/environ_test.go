@@ -0,0 +1,71 @@
+package environ
+
+import (
+	"reflect"
+	"testing"
+)
+
+var (
+	testBase    = []string{"PATH=/bin", "HOME=/root", "API_KEY=from-shell", "PATH=/usr/bin", "NOEQUALS"}
+	testSecrets = map[string]string{"API_KEY": "secret", "DB_URL": "postgres://db", "TOKEN": "t"}
+)
+
+func build(t *testing.T, opts Options) []string {
+	t.Helper()
+	vars, err := Build(testBase, testSecrets, opts)
+	if err != nil {
+		t.Fatalf("Build() error = %v", err)
+	}
+	return List(vars)
+}
+
+func TestBuild(t *testing.T) {
+	t.Run("secrets override the process environment once", func(t *testing.T) {
+		got := build(t, Options{})
+		want := []string{"API_KEY=secret", "DB_URL=postgres://db", "HOME=/root", "PATH=/usr/bin", "TOKEN=t"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Build() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("keeps process variables with NoOverride", func(t *testing.T) {
+		vars, err := Build(testBase, testSecrets, Options{NoOverride: true})
+		if err != nil {
+			t.Fatalf("Build() error = %v", err)
+		}
+		if vars[0].Name != "API_KEY" || vars[0].Value != "from-shell" || vars[0].Key != "" {
+			t.Errorf("API_KEY = %+v, want the process value", vars[0])
+		}
+		if vars[1].Key != "DB_URL" {
+			t.Errorf("DB_URL = %+v, want it from the secrets", vars[1])
+		}
+	})
+
+	t.Run("starts clean with an allowlist", func(t *testing.T) {
+		got := build(t, Options{Clean: true, Keep: []string{"PATH"}, Only: []string{"TOKEN"}})
+		want := []string{"PATH=/usr/bin", "TOKEN=t"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Build() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("selects and prefixes secrets", func(t *testing.T) {
+		got := build(t, Options{Clean: true, Except: []string{"TOKEN"}, Prefix: "APP_"})
+		want := []string{"APP_API_KEY=secret", "APP_DB_URL=postgres://db"}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Build() = %q, want %q", got, want)
+		}
+	})
+
+	t.Run("rejects unknown keys, invalid prefixes and Keep without Clean", func(t *testing.T) {
+		if _, err := Build(testBase, testSecrets, Options{Only: []string{"MISSING"}}); err == nil {
+			t.Error("Build() should fail for an unknown --only key")
+		}
+		if _, err := Build(testBase, testSecrets, Options{Prefix: "APP-"}); err == nil {
+			t.Error("Build() should fail for an invalid prefix")
+		}
+		if _, err := Build(testBase, testSecrets, Options{Keep: []string{"PATH"}}); err == nil {
+			t.Error("Build() should fail for Keep without Clean")
+		}
+	})
+}
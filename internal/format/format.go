@@ -0,0 +1,123 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/schrockwell/sse/internal/dotenv"
+)
+
+// Names lists the supported output formats.
+var Names = []string{"dotenv", "json", "yaml", "docker", "toml"}
+
+// Write writes the values in the named format, with keys in sorted order.
+func Write(w io.Writer, name string, values map[string]string) error {
+	var data []byte
+	var err error
+	switch name {
+	case "dotenv":
+		data = lines(values, func(key, value string) string {
+			return key + "=" + dotenv.Quote(value)
+		})
+	case "docker":
+		data, err = docker(values)
+	case "json":
+		data, err = jsonObject(values)
+	case "yaml":
+		data = yaml(values)
+	case "toml":
+		var buf bytes.Buffer
+		err = toml.NewEncoder(&buf).Encode(values)
+		data = buf.Bytes()
+	default:
+		return fmt.Errorf("unknown format %q (use %s)", name, strings.Join(Names, ", "))
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	return nil
+}
+
+func sortedKeys(values map[string]string) []string {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// lines formats one line per key.
+func lines(values map[string]string, line func(key, value string) string) []byte {
+	var b strings.Builder
+	for _, key := range sortedKeys(values) {
+		b.WriteString(line(key, values[key]))
+		b.WriteByte('\n')
+	}
+	return []byte(b.String())
+}
+
+// docker formats a docker --env-file, which takes every value literally up to the end of
+// the line. Values with line breaks cannot be written to it.
+func docker(values map[string]string) ([]byte, error) {
+	for key, value := range values {
+		if strings.ContainsAny(value, "\n\r") {
+			return nil, fmt.Errorf("%s has a line break, which docker env files cannot hold (use --format dotenv)", key)
+		}
+	}
+	return lines(values, func(key, value string) string {
+		return key + "=" + value
+	}), nil
+}
+
+func jsonObject(values map[string]string) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(values); err != nil {
+		return nil, fmt.Errorf("failed to encode json: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+var plainYAMLKey = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// yamlKeywords are plain scalars that YAML 1.1 reads as booleans or null.
+var yamlKeywords = map[string]bool{
+	"y": true, "yes": true, "n": true, "no": true, "true": true, "false": true,
+	"on": true, "off": true, "null": true,
+}
+
+// yaml formats a YAML mapping. Values are written as JSON strings, which are valid
+// YAML double-quoted scalars.
+func yaml(values map[string]string) []byte {
+	if len(values) == 0 {
+		return []byte("{}\n")
+	}
+	return lines(values, func(key, value string) string {
+		if !plainYAMLKey.MatchString(key) || yamlKeywords[strings.ToLower(key)] {
+			key = jsonString(key)
+		}
+		return key + ": " + jsonString(value)
+	})
+}
+
+// jsonString returns s as a JSON string without HTML escaping.
+func jsonString(s string) string {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(s) // Encoding a string cannot fail
+	return strings.TrimSuffix(buf.String(), "\n")
+}
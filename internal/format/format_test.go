@@ -0,0 +1,103 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/BurntSushi/toml"
+	"github.com/schrockwell/sse/internal/dotenv"
+)
+
+var testValues = map[string]string{
+	"API_KEY": "abc123",
+	"CERT":    "-----BEGIN-----\nline \"two\"\n-----END-----",
+	"QUOTES":  `it's "$HOME" \ <b>`,
+	"ON":      "yes",
+}
+
+func write(t *testing.T, name string, values map[string]string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Write(&buf, name, values); err != nil {
+		t.Fatalf("Write(%s) error = %v", name, err)
+	}
+	return buf.String()
+}
+
+func TestWrite(t *testing.T) {
+	t.Run("dotenv round-trips", func(t *testing.T) {
+		entries, err := dotenv.Parse([]byte(write(t, "dotenv", testValues)))
+		if err != nil {
+			t.Fatalf("dotenv.Parse() error = %v", err)
+		}
+		if len(entries) != len(testValues) {
+			t.Fatalf("got %d entries, want %d", len(entries), len(testValues))
+		}
+		for _, entry := range entries {
+			if entry.Value != testValues[entry.Key] {
+				t.Errorf("%s = %q, want %q", entry.Key, entry.Value, testValues[entry.Key])
+			}
+		}
+	})
+
+	t.Run("json round-trips", func(t *testing.T) {
+		got := write(t, "json", testValues)
+		var decoded map[string]string
+		if err := json.Unmarshal([]byte(got), &decoded); err != nil {
+			t.Fatalf("json.Unmarshal() error = %v", err)
+		}
+		for key, want := range testValues {
+			if decoded[key] != want {
+				t.Errorf("%s = %q, want %q", key, decoded[key], want)
+			}
+		}
+		if !strings.Contains(got, "<b>") {
+			t.Errorf("HTML should not be escaped:\n%s", got)
+		}
+	})
+
+	t.Run("toml round-trips", func(t *testing.T) {
+		var decoded map[string]string
+		if _, err := toml.Decode(write(t, "toml", testValues), &decoded); err != nil {
+			t.Fatalf("toml.Decode() error = %v", err)
+		}
+		for key, want := range testValues {
+			if decoded[key] != want {
+				t.Errorf("%s = %q, want %q", key, decoded[key], want)
+			}
+		}
+	})
+
+	t.Run("yaml quotes values and keyword keys", func(t *testing.T) {
+		got := write(t, "yaml", testValues)
+		want := `API_KEY: "abc123"
+CERT: "-----BEGIN-----\nline \"two\"\n-----END-----"
+"ON": "yes"
+QUOTES: "it's \"$HOME\" \\ <b>"
+`
+		if got != want {
+			t.Errorf("yaml =\n%s\nwant\n%s", got, want)
+		}
+		if got := write(t, "yaml", map[string]string{}); got != "{}\n" {
+			t.Errorf("empty yaml = %q", got)
+		}
+	})
+
+	t.Run("docker writes values literally", func(t *testing.T) {
+		got := write(t, "docker", map[string]string{"A": `x "y" $z`, "B": ""})
+		if want := "A=x \"y\" $z\nB=\n"; got != want {
+			t.Errorf("docker = %q, want %q", got, want)
+		}
+		if err := Write(&bytes.Buffer{}, "docker", testValues); err == nil || !strings.Contains(err.Error(), "CERT") {
+			t.Errorf("Write(docker) error = %v, want a line break error for CERT", err)
+		}
+	})
+
+	t.Run("rejects unknown formats", func(t *testing.T) {
+		if err := Write(&bytes.Buffer{}, "xml", testValues); err == nil {
+			t.Error("Write(xml) should have failed")
+		}
+	})
+}
@@ -0,0 +1,157 @@
+package keyfile
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+	"golang.org/x/crypto/ssh"
+)
+
+// Key is a loaded master key, either from the master.key format or from an SSH private key.
+type Key struct {
+	// Identities holds every identity in the key, primary first.
+	Identities []age.Identity
+	// Recipient is the public key of the primary identity, which new values are encrypted to.
+	Recipient age.Recipient
+	// PublicKey is the recipient in text form, as listed in .sse-recipients.
+	PublicKey string
+	// Private is the secret key in text form, as accepted by SSE_MASTER_KEY. For the
+	// master.key format it holds every secret key, primary first, one per line.
+	Private string
+}
+
+// X25519Identities returns the identities that are age X25519 identities, primary first.
+func (k *Key) X25519Identities() []*age.X25519Identity {
+	var result []*age.X25519Identity
+	for _, identity := range k.Identities {
+		if x, ok := identity.(*age.X25519Identity); ok {
+			result = append(result, x)
+		}
+	}
+	return result
+}
+
+// IsSSH reports whether the primary identity is an SSH key.
+func (k *Key) IsSSH() bool {
+	_, ok := k.Identities[0].(*age.X25519Identity)
+	return !ok
+}
+
+// ReadKey reads a key file, which may hold age identities or an SSH private key.
+// If the key file is passphrase-protected, the passphrase is asked for.
+func ReadKey(path string) (*Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open key file: %w", err)
+	}
+
+	data, err := unlock(raw, path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := parseKey(data, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// LoadKey loads the key from SSE_MASTER_KEY env var, or falls back to the key file at path.
+func LoadKey(path string) (*Key, error) {
+	if data, err := masterKeyEnv(); err != nil {
+		return nil, err
+	} else if data != "" {
+		key, err := parseKey(data, MasterKeyEnvVar)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", MasterKeyEnvVar, err)
+		}
+		return key, nil
+	}
+	return ReadKey(path)
+}
+
+// parseKey parses key data in the master.key format or as an SSH private key.
+// source names where the data came from and is used when asking for a passphrase.
+func parseKey(data, source string) (*Key, error) {
+	if isSSHKey(data) {
+		return parseSSHKey(data, source)
+	}
+
+	identities, recipient, err := parseAll(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(identities) == 0 {
+		return nil, fmt.Errorf("no secret key found")
+	}
+
+	private := make([]string, 0, len(identities))
+	for _, identity := range identities {
+		private = append(private, identity.String())
+	}
+
+	return &Key{
+		Identities: toIdentities(identities),
+		Recipient:  recipient,
+		PublicKey:  recipient.String(),
+		Private:    strings.Join(private, "\n"),
+	}, nil
+}
+
+// isSSHKey reports whether data looks like a PEM-encoded SSH private key.
+func isSSHKey(data string) bool {
+	data = strings.TrimSpace(data)
+	return strings.HasPrefix(data, "-----BEGIN ") && strings.Contains(data, "PRIVATE KEY-----")
+}
+
+// parseSSHKey parses an ed25519 or RSA SSH private key. Passphrase-protected SSH keys
+// ask for their passphrase only when a value is actually decrypted.
+func parseSSHKey(data, source string) (*Key, error) {
+	pem := []byte(strings.TrimSpace(data) + "\n")
+
+	var identity age.Identity
+	var pubKey ssh.PublicKey
+
+	signer, err := ssh.ParsePrivateKey(pem)
+	var missing *ssh.PassphraseMissingError
+	switch {
+	case errors.As(err, &missing):
+		if missing.PublicKey == nil {
+			return nil, fmt.Errorf("encrypted SSH key has no embedded public key; convert it to the OpenSSH format")
+		}
+		pubKey = missing.PublicKey
+		identity, err = agessh.NewEncryptedSSHIdentity(pubKey, pem, func() ([]byte, error) {
+			passphrase, err := ReadPassphrase(fmt.Sprintf("Enter passphrase for %s: ", source))
+			return []byte(passphrase), err
+		})
+		if err != nil {
+			return nil, err
+		}
+	case err != nil:
+		return nil, fmt.Errorf("failed to parse SSH key: %w", err)
+	default:
+		pubKey = signer.PublicKey()
+		identity, err = agessh.ParseIdentity(pem)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse SSH key: %w", err)
+		}
+	}
+
+	publicKey := strings.TrimSpace(string(ssh.MarshalAuthorizedKey(pubKey)))
+	recipient, err := agessh.ParseRecipient(publicKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Key{
+		Identities: []age.Identity{identity},
+		Recipient:  recipient,
+		PublicKey:  publicKey,
+		Private:    string(pem),
+	}, nil
+}
@@ -1,7 +1,7 @@
 package keyfile
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
 	"os"
 	"strings"
@@ -12,9 +12,16 @@ import (
 
 const DefaultKeyFile = "master.key"
 const MasterKeyEnvVar = "SSE_MASTER_KEY"
+const KeyFileEnvVar = "SSE_KEY_FILE"
 
 // Generate creates a new age X25519 identity and writes it to the specified file.
 func Generate(path string, force bool) error {
+	return GenerateProtected(path, force, "")
+}
+
+// GenerateProtected creates a new age X25519 identity and writes it to the specified file,
+// encrypted with the passphrase. An empty passphrase writes the key in plaintext.
+func GenerateProtected(path string, force bool, passphrase string) error {
 	if !force {
 		if _, err := os.Stat(path); err == nil {
 			return fmt.Errorf("key file %s already exists (use --force to overwrite)", path)
@@ -26,68 +33,84 @@ func Generate(path string, force bool) error {
 		return fmt.Errorf("failed to generate keypair: %w", err)
 	}
 
+	return WriteProtected(path, passphrase, identity)
+}
+
+// Write writes the identities to the specified file, replacing any existing contents.
+// The first identity is the primary one; any others are kept for decrypting values
+// that are still encrypted to them, such as during a key rotation.
+func Write(path string, identities ...*age.X25519Identity) error {
+	return WriteProtected(path, "", identities...)
+}
+
+// WriteProtected writes the identities like Write, encrypted with the passphrase.
+// An empty passphrase writes the key in plaintext.
+func WriteProtected(path, passphrase string, identities ...*age.X25519Identity) error {
+	if len(identities) == 0 {
+		return fmt.Errorf("no identity to write")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "# created: %s\n", time.Now().Format(time.RFC3339))
+	for _, identity := range identities {
+		fmt.Fprintf(&buf, "# public key: %s\n", identity.Recipient().String())
+		fmt.Fprintf(&buf, "%s\n", identity.String())
+	}
+
+	data := buf.Bytes()
+	if passphrase != "" {
+		sealed, err := seal(data, passphrase)
+		if err != nil {
+			return err
+		}
+		data = sealed
+	}
+
 	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
 		return fmt.Errorf("failed to create key file: %w", err)
 	}
 	defer f.Close()
 
-	fmt.Fprintf(f, "# created: %s\n", time.Now().Format(time.RFC3339))
-	fmt.Fprintf(f, "# public key: %s\n", identity.Recipient().String())
-	fmt.Fprintf(f, "%s\n", identity.String())
+	if _, err := f.Write(data); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
 
 	return nil
 }
 
-// Read reads the key file and returns the age identity and recipient.
+// Read reads the key file and returns the primary age identity and recipient.
 func Read(path string) (*age.X25519Identity, *age.X25519Recipient, error) {
-	f, err := os.Open(path)
+	identities, recipient, err := ReadAll(path)
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to open key file: %w", err)
+		return nil, nil, err
 	}
-	defer f.Close()
-
-	var identity *age.X25519Identity
-	var recipient *age.X25519Recipient
+	return identities[0], recipient, nil
+}
 
-	scanner := bufio.NewScanner(f)
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
-		if line == "" || strings.HasPrefix(line, "#") {
-			// Extract public key from comment if present
-			if strings.HasPrefix(line, "# public key: ") {
-				pubKey := strings.TrimPrefix(line, "# public key: ")
-				r, err := age.ParseX25519Recipient(pubKey)
-				if err == nil {
-					recipient = r
-				}
-			}
-			continue
-		}
+// ReadAll reads every identity in the key file, primary first, along with the primary recipient.
+// If the key file is passphrase-protected, the passphrase is asked for.
+func ReadAll(path string) ([]*age.X25519Identity, *age.X25519Recipient, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to open key file: %w", err)
+	}
 
-		if strings.HasPrefix(line, "AGE-SECRET-KEY-") {
-			id, err := age.ParseX25519Identity(line)
-			if err != nil {
-				return nil, nil, fmt.Errorf("failed to parse secret key: %w", err)
-			}
-			identity = id
-			// Derive recipient from identity if not already set
-			if recipient == nil {
-				recipient = identity.Recipient()
-			}
-			break
-		}
+	data, err := unlock(raw, path)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if err := scanner.Err(); err != nil {
-		return nil, nil, fmt.Errorf("failed to read key file: %w", err)
+	identities, recipient, err := parseAll(data)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	if identity == nil {
+	if len(identities) == 0 {
 		return nil, nil, fmt.Errorf("no secret key found in key file")
 	}
 
-	return identity, recipient, nil
+	return identities, recipient, nil
 }
 
 // ReadRecipient reads only the public key from the key file.
@@ -96,21 +119,48 @@ func ReadRecipient(path string) (*age.X25519Recipient, error) {
 	return recipient, err
 }
 
-// ReadIdentity reads only the secret key from the key file.
+// ReadIdentity reads only the primary secret key from the key file.
 func ReadIdentity(path string) (*age.X25519Identity, error) {
 	identity, _, err := Read(path)
 	return identity, err
 }
 
-// parseKeyData parses the master.key file format from a string.
+// ReadIdentities reads every secret key from the key file, primary first.
+// The key file may also be an SSH private key.
+func ReadIdentities(path string) ([]age.Identity, error) {
+	key, err := ReadKey(path)
+	if err != nil {
+		return nil, err
+	}
+	return key.Identities, nil
+}
+
+// parseKeyData parses the master.key file format from a string and returns the primary identity.
 func parseKeyData(data string) (*age.X25519Identity, *age.X25519Recipient, error) {
-	var identity *age.X25519Identity
+	identities, recipient, err := parseAll(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if len(identities) == 0 {
+		return nil, nil, fmt.Errorf("no secret key found")
+	}
+
+	return identities[0], recipient, nil
+}
+
+// parseAll parses every secret key in the master.key file format. The first key is the
+// primary identity; the recipient is taken from the public key comment preceding it, or
+// derived from it.
+func parseAll(data string) ([]*age.X25519Identity, *age.X25519Recipient, error) {
+	var identities []*age.X25519Identity
 	var recipient *age.X25519Recipient
 
 	for _, line := range strings.Split(data, "\n") {
 		line = strings.TrimSpace(line)
 		if line == "" || strings.HasPrefix(line, "#") {
-			if strings.HasPrefix(line, "# public key: ") {
+			// Extract the primary public key from its comment if present
+			if strings.HasPrefix(line, "# public key: ") && len(identities) == 0 {
 				pubKey := strings.TrimPrefix(line, "# public key: ")
 				r, err := age.ParseX25519Recipient(pubKey)
 				if err == nil {
@@ -125,41 +175,31 @@ func parseKeyData(data string) (*age.X25519Identity, *age.X25519Recipient, error
 			if err != nil {
 				return nil, nil, fmt.Errorf("failed to parse secret key: %w", err)
 			}
-			identity = id
+			identities = append(identities, id)
+			// Derive recipient from the primary identity if not already set
 			if recipient == nil {
-				recipient = identity.Recipient()
+				recipient = id.Recipient()
 			}
-			break
 		}
 	}
 
-	if identity == nil {
-		return nil, nil, fmt.Errorf("no secret key found")
-	}
-
-	return identity, recipient, nil
+	return identities, recipient, nil
 }
 
-// LoadIdentity loads the identity from SSE_MASTER_KEY env var, or falls back to the default key file.
-func LoadIdentity() (*age.X25519Identity, error) {
-	if key := os.Getenv(MasterKeyEnvVar); key != "" {
-		identity, _, err := parseKeyData(key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse %s: %w", MasterKeyEnvVar, err)
-		}
-		return identity, nil
+// toIdentities converts X25519 identities to the age.Identity interface.
+func toIdentities(identities []*age.X25519Identity) []age.Identity {
+	result := make([]age.Identity, 0, len(identities))
+	for _, identity := range identities {
+		result = append(result, identity)
 	}
-	return ReadIdentity(DefaultKeyFile)
+	return result
 }
 
-// LoadRecipient loads the recipient (public key) from SSE_MASTER_KEY env var, or falls back to the default key file.
-func LoadRecipient() (*age.X25519Recipient, error) {
-	if key := os.Getenv(MasterKeyEnvVar); key != "" {
-		_, recipient, err := parseKeyData(key)
-		if err != nil {
-			return nil, fmt.Errorf("failed to parse %s: %w", MasterKeyEnvVar, err)
-		}
-		return recipient, nil
+// masterKeyEnv returns the key data from SSE_MASTER_KEY, unlocking it if it is passphrase-protected.
+func masterKeyEnv() (string, error) {
+	key := os.Getenv(MasterKeyEnvVar)
+	if key == "" {
+		return "", nil
 	}
-	return ReadRecipient(DefaultKeyFile)
+	return unlock([]byte(key), MasterKeyEnvVar)
 }
@@ -92,6 +92,36 @@ func TestGenerate(t *testing.T) {
 	})
 }
 
+func TestWrite(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.key")
+
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+
+	if err := Write(path, identity); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("key file permissions = %v, want 0600", info.Mode().Perm())
+	}
+
+	read, recipient, err := Read(path)
+	if err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+	if read.String() != identity.String() {
+		t.Error("read identity does not match written identity")
+	}
+	if recipient.String() != identity.Recipient().String() {
+		t.Error("read recipient does not match written identity")
+	}
+}
+
 func TestRead(t *testing.T) {
 	t.Run("reads valid key file", func(t *testing.T) {
 		dir := t.TempDir()
@@ -162,6 +192,61 @@ func TestRead(t *testing.T) {
 	})
 }
 
+func TestReadAll(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.key")
+
+	primary, _ := age.GenerateX25519Identity()
+	secondary, _ := age.GenerateX25519Identity()
+	if err := Write(path, primary, secondary); err != nil {
+		t.Fatalf("Write() error = %v", err)
+	}
+
+	t.Run("reads every identity with the primary first", func(t *testing.T) {
+		identities, recipient, err := ReadAll(path)
+		if err != nil {
+			t.Fatalf("ReadAll() error = %v", err)
+		}
+		if len(identities) != 2 {
+			t.Fatalf("len(identities) = %d, want 2", len(identities))
+		}
+		if identities[0].String() != primary.String() || identities[1].String() != secondary.String() {
+			t.Error("identities are not in file order")
+		}
+		if recipient.String() != primary.Recipient().String() {
+			t.Error("recipient should belong to the primary identity")
+		}
+	})
+
+	t.Run("Read returns the primary identity", func(t *testing.T) {
+		identity, recipient, err := Read(path)
+		if err != nil {
+			t.Fatalf("Read() error = %v", err)
+		}
+		if identity.String() != primary.String() {
+			t.Error("Read() should return the primary identity")
+		}
+		if recipient.String() != primary.Recipient().String() {
+			t.Error("Read() should return the primary recipient")
+		}
+	})
+
+	t.Run("reads concatenated identity files", func(t *testing.T) {
+		first, _ := generateTestKey(t)
+		second, _ := generateTestKey(t)
+		concatenated := filepath.Join(dir, "concatenated.key")
+		os.WriteFile(concatenated, []byte(first+"\n"+second), 0600)
+
+		identities, err := ReadIdentities(concatenated)
+		if err != nil {
+			t.Fatalf("ReadIdentities() error = %v", err)
+		}
+		if len(identities) != 2 {
+			t.Errorf("len(identities) = %d, want 2", len(identities))
+		}
+	})
+}
+
 func TestReadIdentity(t *testing.T) {
 	dir := t.TempDir()
 	path := filepath.Join(dir, "test.key")
@@ -246,59 +331,3 @@ func TestParseKeyData(t *testing.T) {
 		}
 	})
 }
-
-func TestLoadIdentity(t *testing.T) {
-	t.Run("loads from environment variable", func(t *testing.T) {
-		_, secretKey := generateTestKey(t)
-		os.Setenv(MasterKeyEnvVar, secretKey)
-		defer os.Unsetenv(MasterKeyEnvVar)
-
-		identity, err := LoadIdentity()
-		if err != nil {
-			t.Fatalf("LoadIdentity() error = %v", err)
-		}
-		if identity == nil {
-			t.Fatal("identity is nil")
-		}
-	})
-
-	t.Run("loads full key file format from environment", func(t *testing.T) {
-		keyFileContent, _ := generateTestKey(t)
-		os.Setenv(MasterKeyEnvVar, keyFileContent)
-		defer os.Unsetenv(MasterKeyEnvVar)
-
-		identity, err := LoadIdentity()
-		if err != nil {
-			t.Fatalf("LoadIdentity() error = %v", err)
-		}
-		if identity == nil {
-			t.Fatal("identity is nil")
-		}
-	})
-
-	t.Run("fails for invalid environment variable", func(t *testing.T) {
-		os.Setenv(MasterKeyEnvVar, "invalid-key")
-		defer os.Unsetenv(MasterKeyEnvVar)
-
-		_, err := LoadIdentity()
-		if err == nil {
-			t.Fatal("LoadIdentity() should have failed for invalid key")
-		}
-	})
-}
-
-func TestLoadRecipient(t *testing.T) {
-	t.Run("loads from environment variable", func(t *testing.T) {
-		_, secretKey := generateTestKey(t)
-		os.Setenv(MasterKeyEnvVar, secretKey)
-		defer os.Unsetenv(MasterKeyEnvVar)
-
-		recipient, err := LoadRecipient()
-		if err != nil {
-			t.Fatalf("LoadRecipient() error = %v", err)
-		}
-		if recipient == nil {
-			t.Fatal("recipient is nil")
-		}
-	})
-}
@@ -0,0 +1,212 @@
+package keyfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/armor"
+	ageutil "github.com/schrockwell/sse/internal/age"
+	"golang.org/x/term"
+)
+
+const PassphraseFDEnvVar = "SSE_PASSPHRASE_FD"
+
+// scryptWorkFactor is the scrypt work factor (log2 N) used to protect key files.
+var scryptWorkFactor = 18
+
+// passphraseFiles keeps the files opened from SSE_PASSPHRASE_FD reachable. The descriptor
+// belongs to the caller, and a collected *os.File would close it behind their back.
+var passphraseFiles []*os.File
+
+// cachedPassphrase holds the passphrase once it has been read, so that a command
+// that loads the key more than once only asks for it a single time.
+var cachedPassphrase string
+
+// IsProtected reports whether key file data is wrapped in a passphrase-encrypted age envelope.
+func IsProtected(data []byte) bool {
+	return strings.HasPrefix(strings.TrimSpace(string(data)), armor.Header)
+}
+
+// Protect encrypts the key file at path with a passphrase, replacing its contents.
+func Protect(path, passphrase string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+	if IsProtected(data) {
+		return fmt.Errorf("key file %s is already protected", path)
+	}
+	if _, _, err := parseKeyData(string(data)); err != nil {
+		return err
+	}
+
+	sealed, err := seal(data, passphrase)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, sealed)
+}
+
+// Unprotect decrypts a passphrase-protected key file at path, replacing its contents.
+func Unprotect(path, passphrase string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read key file: %w", err)
+	}
+	if !IsProtected(data) {
+		return fmt.Errorf("key file %s is not protected", path)
+	}
+
+	opened, err := open(data, passphrase)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, opened)
+}
+
+// seal encrypts data to an age scrypt recipient.
+func seal(data []byte, passphrase string) ([]byte, error) {
+	if passphrase == "" {
+		return nil, fmt.Errorf("passphrase must not be empty")
+	}
+	recipient, err := age.NewScryptRecipient(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create passphrase recipient: %w", err)
+	}
+	recipient.SetWorkFactor(scryptWorkFactor)
+	return ageutil.Encrypt(data, recipient)
+}
+
+// open decrypts data that was sealed with a passphrase.
+func open(data []byte, passphrase string) ([]byte, error) {
+	identity, err := age.NewScryptIdentity(passphrase)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create passphrase identity: %w", err)
+	}
+	plaintext, err := ageutil.Decrypt(bytes.TrimSpace(data), identity)
+	if err != nil {
+		var noMatch *age.NoIdentityMatchError
+		if errors.As(err, &noMatch) {
+			return nil, fmt.Errorf("incorrect passphrase")
+		}
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// unlock returns key data as plaintext, asking for the passphrase if it is protected.
+// source names where the data came from and is used in the prompt.
+func unlock(data []byte, source string) (string, error) {
+	if !IsProtected(data) {
+		return string(data), nil
+	}
+
+	passphrase, err := ReadPassphrase(fmt.Sprintf("Enter passphrase for %s: ", source))
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(data, passphrase)
+	if err != nil {
+		cachedPassphrase = ""
+		return "", fmt.Errorf("failed to unlock %s: %w", source, err)
+	}
+	return string(plaintext), nil
+}
+
+// replaceFile writes data to a temporary file next to path and renames it into place.
+func replaceFile(path string, data []byte) error {
+	tmp := path + ".new"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return fmt.Errorf("failed to write key file: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to replace key file: %w", err)
+	}
+	return nil
+}
+
+// ReadPassphrase returns the passphrase for a protected key. It is read from the file
+// descriptor named by SSE_PASSPHRASE_FD if set, or prompted for on the terminal.
+func ReadPassphrase(prompt string) (string, error) {
+	if cachedPassphrase != "" {
+		return cachedPassphrase, nil
+	}
+
+	passphrase, err := readPassphrase(prompt)
+	if err != nil {
+		return "", err
+	}
+	cachedPassphrase = passphrase
+	return passphrase, nil
+}
+
+// ReadNewPassphrase asks for a new passphrase. On a terminal it is asked for twice to confirm it.
+func ReadNewPassphrase() (string, error) {
+	if os.Getenv(PassphraseFDEnvVar) != "" {
+		return ReadPassphrase("")
+	}
+
+	passphrase, err := readPassphrase("Enter new passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase == "" {
+		return "", fmt.Errorf("passphrase must not be empty")
+	}
+	confirm, err := readPassphrase("Confirm passphrase: ")
+	if err != nil {
+		return "", err
+	}
+	if passphrase != confirm {
+		return "", fmt.Errorf("passphrases do not match")
+	}
+
+	cachedPassphrase = passphrase
+	return passphrase, nil
+}
+
+// readPassphrase reads a single passphrase from SSE_PASSPHRASE_FD or the terminal.
+func readPassphrase(prompt string) (string, error) {
+	if fdStr := os.Getenv(PassphraseFDEnvVar); fdStr != "" {
+		fd, err := strconv.Atoi(fdStr)
+		if err != nil {
+			return "", fmt.Errorf("invalid %s: %w", PassphraseFDEnvVar, err)
+		}
+		f := os.NewFile(uintptr(fd), "passphrase")
+		if f == nil {
+			return "", fmt.Errorf("invalid %s: %d", PassphraseFDEnvVar, fd)
+		}
+		passphraseFiles = append(passphraseFiles, f)
+		line, err := bufio.NewReader(f).ReadString('\n')
+		if err != nil && line == "" {
+			return "", fmt.Errorf("failed to read passphrase from %s: %w", PassphraseFDEnvVar, err)
+		}
+		return strings.TrimRight(line, "\r\n"), nil
+	}
+
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return "", fmt.Errorf("master key is passphrase-protected but no terminal is available (set %s)", PassphraseFDEnvVar)
+		}
+		tty = os.Stdin
+	} else {
+		defer tty.Close()
+	}
+
+	fmt.Fprint(os.Stderr, prompt)
+	data, err := term.ReadPassword(int(tty.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read passphrase: %w", err)
+	}
+	return string(data), nil
+}
@@ -0,0 +1,167 @@
+package keyfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// usePassphrase makes the passphrase available through SSE_PASSPHRASE_FD for the duration of the test.
+func usePassphrase(t *testing.T, passphrase string) {
+	t.Helper()
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatalf("failed to create pipe: %v", err)
+	}
+	fmt.Fprintln(w, passphrase)
+	w.Close()
+
+	oldWorkFactor := scryptWorkFactor
+	scryptWorkFactor = 10
+	cachedPassphrase = ""
+	os.Setenv(PassphraseFDEnvVar, fmt.Sprint(r.Fd()))
+
+	t.Cleanup(func() {
+		r.Close()
+		os.Unsetenv(PassphraseFDEnvVar)
+		cachedPassphrase = ""
+		scryptWorkFactor = oldWorkFactor
+	})
+}
+
+func TestIsProtected(t *testing.T) {
+	tests := []struct {
+		data     string
+		expected bool
+	}{
+		{"-----BEGIN AGE ENCRYPTED FILE-----\nabc\n-----END AGE ENCRYPTED FILE-----\n", true},
+		{"\n-----BEGIN AGE ENCRYPTED FILE-----\n", true},
+		{"# public key: age1...\nAGE-SECRET-KEY-1...\n", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsProtected([]byte(tt.data)); got != tt.expected {
+			t.Errorf("IsProtected(%q) = %v, want %v", tt.data, got, tt.expected)
+		}
+	}
+}
+
+func TestProtectAndUnprotect(t *testing.T) {
+	t.Run("protected key file is read with the passphrase", func(t *testing.T) {
+		usePassphrase(t, "correct horse")
+		dir := t.TempDir()
+		path := filepath.Join(dir, "test.key")
+		Generate(path, false)
+		original, _ := ReadIdentity(path)
+
+		if err := Protect(path, "correct horse"); err != nil {
+			t.Fatalf("Protect() error = %v", err)
+		}
+
+		data, _ := os.ReadFile(path)
+		if strings.Contains(string(data), "AGE-SECRET-KEY-") {
+			t.Error("protected key file should not contain the plaintext secret key")
+		}
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0600 {
+			t.Errorf("key file permissions = %v, want 0600", info.Mode().Perm())
+		}
+
+		identity, err := ReadIdentity(path)
+		if err != nil {
+			t.Fatalf("ReadIdentity() error = %v", err)
+		}
+		if identity.String() != original.String() {
+			t.Error("identity read from protected file does not match original")
+		}
+
+		if err := Unprotect(path, "correct horse"); err != nil {
+			t.Fatalf("Unprotect() error = %v", err)
+		}
+		data, _ = os.ReadFile(path)
+		if !strings.Contains(string(data), original.String()) {
+			t.Error("unprotected key file should contain the plaintext secret key")
+		}
+	})
+
+	t.Run("fails with wrong passphrase", func(t *testing.T) {
+		usePassphrase(t, "wrong")
+		dir := t.TempDir()
+		path := filepath.Join(dir, "test.key")
+		Generate(path, false)
+		Protect(path, "right")
+
+		_, err := ReadIdentity(path)
+		if err == nil {
+			t.Fatal("ReadIdentity() should have failed with wrong passphrase")
+		}
+		if !strings.Contains(err.Error(), "incorrect passphrase") {
+			t.Errorf("error = %v, want 'incorrect passphrase' message", err)
+		}
+	})
+
+	t.Run("Protect fails for protected file", func(t *testing.T) {
+		usePassphrase(t, "pass")
+		dir := t.TempDir()
+		path := filepath.Join(dir, "test.key")
+		Generate(path, false)
+		Protect(path, "pass")
+
+		if err := Protect(path, "pass"); err == nil {
+			t.Error("Protect() should have failed for protected file")
+		}
+	})
+
+	t.Run("Unprotect fails for plaintext file", func(t *testing.T) {
+		dir := t.TempDir()
+		path := filepath.Join(dir, "test.key")
+		Generate(path, false)
+
+		if err := Unprotect(path, "pass"); err == nil {
+			t.Error("Unprotect() should have failed for plaintext file")
+		}
+	})
+}
+
+func TestGenerateProtected(t *testing.T) {
+	usePassphrase(t, "secret")
+	dir := t.TempDir()
+	path := filepath.Join(dir, "test.key")
+
+	if err := GenerateProtected(path, false, "secret"); err != nil {
+		t.Fatalf("GenerateProtected() error = %v", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	if !IsProtected(data) {
+		t.Error("generated key file should be protected")
+	}
+
+	if _, _, err := Read(path); err != nil {
+		t.Fatalf("Read() error = %v", err)
+	}
+}
+
+func TestLoadProtectedFromEnvironment(t *testing.T) {
+	usePassphrase(t, "secret")
+	keyFileContent, secretKey := generateTestKey(t)
+
+	sealed, err := seal([]byte(keyFileContent), "secret")
+	if err != nil {
+		t.Fatalf("seal() error = %v", err)
+	}
+	os.Setenv(MasterKeyEnvVar, string(sealed))
+	defer os.Unsetenv(MasterKeyEnvVar)
+
+	key, err := LoadKey("/nonexistent/path")
+	if err != nil {
+		t.Fatalf("LoadKey() error = %v", err)
+	}
+	if key.Private != secretKey {
+		t.Error("identity does not match the sealed key")
+	}
+}
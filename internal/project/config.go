@@ -0,0 +1,105 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+)
+
+// Config holds the per-project defaults stored in .sse.toml. Paths are relative to the
+// directory that contains .sse.toml. Empty fields fall back to the built-in defaults.
+type Config struct {
+	Env          string   `toml:"env,omitempty"`
+	File         string   `toml:"file,omitempty"`
+	Key          string   `toml:"key,omitempty"`
+	Recipients   string   `toml:"recipients,omitempty"`
+	Editor       string   `toml:"editor,omitempty"`
+	Environments []string `toml:"environments,omitempty"`
+}
+
+// Settings lists the names of the settings in .sse.toml, in display order.
+var Settings = []string{"env", "file", "key", "recipients", "editor", "environments"}
+
+// LoadConfig reads a config file. A missing file returns an empty config.
+func LoadConfig(path string) (*Config, error) {
+	c := &Config{}
+	md, err := toml.DecodeFile(path, c)
+	if errors.Is(err, os.ErrNotExist) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	if undecoded := md.Undecoded(); len(undecoded) > 0 {
+		return nil, fmt.Errorf("unknown setting %q in %s", undecoded[0].String(), path)
+	}
+
+	return c, nil
+}
+
+// Save writes the config file.
+func (c *Config) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create config file: %w", err)
+	}
+	defer f.Close()
+
+	if err := toml.NewEncoder(f).Encode(c); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	return nil
+}
+
+// Get returns a setting as a string. Lists are joined with commas.
+func (c *Config) Get(name string) (string, error) {
+	switch name {
+	case "env":
+		return c.Env, nil
+	case "file":
+		return c.File, nil
+	case "key":
+		return c.Key, nil
+	case "recipients":
+		return c.Recipients, nil
+	case "editor":
+		return c.Editor, nil
+	case "environments":
+		return strings.Join(c.Environments, ","), nil
+	}
+	return "", unknownSetting(name)
+}
+
+// Set changes a setting from a string. Lists are split on commas; an empty value clears the setting.
+func (c *Config) Set(name, value string) error {
+	switch name {
+	case "env":
+		c.Env = value
+	case "file":
+		c.File = value
+	case "key":
+		c.Key = value
+	case "recipients":
+		c.Recipients = value
+	case "editor":
+		c.Editor = value
+	case "environments":
+		c.Environments = nil
+		for _, env := range strings.Split(value, ",") {
+			if env = strings.TrimSpace(env); env != "" {
+				c.Environments = append(c.Environments, env)
+			}
+		}
+	default:
+		return unknownSetting(name)
+	}
+	return nil
+}
+
+func unknownSetting(name string) error {
+	return fmt.Errorf("unknown setting %q (expected one of %s)", name, strings.Join(Settings, ", "))
+}
@@ -0,0 +1,106 @@
+package project
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("parses settings", func(t *testing.T) {
+		path := filepath.Join(dir, "parse.toml")
+		os.WriteFile(path, []byte(`env = "staging"
+file = "config/env.toml"
+environments = ["staging", "production"]
+`), 0644)
+
+		c, err := LoadConfig(path)
+		if err != nil {
+			t.Fatalf("LoadConfig() error = %v", err)
+		}
+		if c.Env != "staging" || c.File != "config/env.toml" {
+			t.Errorf("config = %+v", c)
+		}
+		if !reflect.DeepEqual(c.Environments, []string{"staging", "production"}) {
+			t.Errorf("Environments = %v", c.Environments)
+		}
+	})
+
+	t.Run("returns an empty config for a missing file", func(t *testing.T) {
+		c, err := LoadConfig(filepath.Join(dir, "missing.toml"))
+		if err != nil {
+			t.Fatalf("LoadConfig() error = %v", err)
+		}
+		if !reflect.DeepEqual(c, &Config{}) {
+			t.Errorf("config = %+v, want empty", c)
+		}
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		path := filepath.Join(dir, "unknown.toml")
+		os.WriteFile(path, []byte(`enviroment = "staging"`+"\n"), 0644)
+
+		_, err := LoadConfig(path)
+		if err == nil || !strings.Contains(err.Error(), "enviroment") {
+			t.Errorf("LoadConfig() error = %v, want unknown setting", err)
+		}
+	})
+}
+
+func TestConfigGetAndSet(t *testing.T) {
+	t.Run("round-trips every setting", func(t *testing.T) {
+		c := &Config{}
+		for _, name := range Settings {
+			if err := c.Set(name, "a,b"); err != nil {
+				t.Fatalf("Set(%q) error = %v", name, err)
+			}
+			got, err := c.Get(name)
+			if err != nil {
+				t.Fatalf("Get(%q) error = %v", name, err)
+			}
+			if got != "a,b" {
+				t.Errorf("Get(%q) = %q, want %q", name, got, "a,b")
+			}
+		}
+	})
+
+	t.Run("splits environments", func(t *testing.T) {
+		c := &Config{}
+		c.Set("environments", "development, staging,,production")
+		want := []string{"development", "staging", "production"}
+		if !reflect.DeepEqual(c.Environments, want) {
+			t.Errorf("Environments = %v, want %v", c.Environments, want)
+		}
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		c := &Config{}
+		if err := c.Set("nope", "x"); err == nil {
+			t.Error("Set() should have failed")
+		}
+		if _, err := c.Get("nope"); err == nil {
+			t.Error("Get() should have failed")
+		}
+	})
+}
+
+func TestSaveConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ConfigFile)
+	c := &Config{Env: "staging", Environments: []string{"staging", "production"}}
+
+	if err := c.Save(path); err != nil {
+		t.Fatalf("Save() error = %v", err)
+	}
+
+	loaded, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+	if !reflect.DeepEqual(loaded, c) {
+		t.Errorf("loaded = %+v, want %+v", loaded, c)
+	}
+}
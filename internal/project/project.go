@@ -0,0 +1,42 @@
+package project
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+const ConfigFile = ".sse.toml"
+
+// Markers are the files that identify a project root, in the order they are checked.
+var Markers = []string{"env.toml", ConfigFile}
+
+// ErrNotFound is returned by Find when no project root is found.
+var ErrNotFound = errors.New("project not found")
+
+// Find walks up from dir until it finds a directory containing env.toml or .sse.toml,
+// and returns that directory as an absolute path.
+func Find(dir string) (string, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+
+	start := dir
+	for {
+		for _, marker := range Markers {
+			if _, err := os.Stat(filepath.Join(dir, marker)); err == nil {
+				return dir, nil
+			} else if !errors.Is(err, os.ErrNotExist) {
+				return "", fmt.Errorf("failed to check %s: %w", filepath.Join(dir, marker), err)
+			}
+		}
+
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return "", fmt.Errorf("no %s or %s found in %s or any parent directory: %w", Markers[0], Markers[1], start, ErrNotFound)
+		}
+		dir = parent
+	}
+}
@@ -0,0 +1,63 @@
+package project
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	t.Run("finds env.toml in the starting directory", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "env.toml"), nil, 0644)
+
+		root, err := Find(dir)
+		if err != nil {
+			t.Fatalf("Find() error = %v", err)
+		}
+		if root != dir {
+			t.Errorf("root = %q, want %q", root, dir)
+		}
+	})
+
+	t.Run("walks up to a parent directory", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "env.toml"), nil, 0644)
+		sub := filepath.Join(dir, "app", "web")
+		os.MkdirAll(sub, 0755)
+
+		root, err := Find(sub)
+		if err != nil {
+			t.Fatalf("Find() error = %v", err)
+		}
+		if root != dir {
+			t.Errorf("root = %q, want %q", root, dir)
+		}
+	})
+
+	t.Run("stops at the nearest project", func(t *testing.T) {
+		dir := t.TempDir()
+		os.WriteFile(filepath.Join(dir, "env.toml"), nil, 0644)
+		nested := filepath.Join(dir, "nested")
+		os.MkdirAll(nested, 0755)
+		os.WriteFile(filepath.Join(nested, ConfigFile), nil, 0644)
+
+		root, err := Find(filepath.Join(nested))
+		if err != nil {
+			t.Fatalf("Find() error = %v", err)
+		}
+		if root != nested {
+			t.Errorf("root = %q, want %q", root, nested)
+		}
+	})
+
+	t.Run("returns ErrNotFound without a project", func(t *testing.T) {
+		dir := t.TempDir()
+
+		_, err := Find(dir)
+		if !errors.Is(err, ErrNotFound) {
+			t.Errorf("Find() error = %v, want ErrNotFound", err)
+		}
+	})
+}
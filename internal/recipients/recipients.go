@@ -0,0 +1,246 @@
+package recipients
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"filippo.io/age"
+	"filippo.io/age/agessh"
+)
+
+const DefaultFile = ".sse-recipients"
+
+// List holds the public keys that values in env.toml are encrypted to.
+//
+// Keys applies to every environment. An environment listed in Environments
+// is encrypted to its own keys instead, so that e.g. production can be
+// restricted to a smaller set of people than development.
+type List struct {
+	Keys         []string
+	Environments map[string][]string
+}
+
+// PathFor returns the path of the recipients file that sits next to the given secrets file.
+func PathFor(secretsPath string) string {
+	return filepath.Join(filepath.Dir(secretsPath), DefaultFile)
+}
+
+// Load reads a recipients file. Blank lines and lines starting with # are ignored.
+// A [name] line starts the recipients for a single environment.
+func Load(path string) (*List, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open recipients file: %w", err)
+	}
+	defer f.Close()
+
+	l := &List{}
+	section := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			if section == "" {
+				return nil, fmt.Errorf("empty environment name in recipients file")
+			}
+			if l.Environments == nil {
+				l.Environments = make(map[string][]string)
+			}
+			if _, ok := l.Environments[section]; !ok {
+				l.Environments[section] = []string{}
+			}
+			continue
+		}
+
+		if _, err := Parse(line); err != nil {
+			return nil, err
+		}
+		if section == "" {
+			l.Keys = append(l.Keys, line)
+		} else {
+			l.Environments[section] = append(l.Environments[section], line)
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read recipients file: %w", err)
+	}
+
+	return l, nil
+}
+
+// Save writes the recipients file to disk.
+func (l *List) Save(path string) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create recipients file: %w", err)
+	}
+	defer f.Close()
+
+	fmt.Fprintln(f, "# Public keys that can decrypt env.toml, one per line.")
+	fmt.Fprintln(f, "# Managed by `sse recipients add/remove`, which re-encrypts env.toml.")
+	for _, key := range l.Keys {
+		fmt.Fprintln(f, key)
+	}
+
+	for _, envName := range l.EnvironmentNames() {
+		fmt.Fprintf(f, "\n[%s]\n", envName)
+		for _, key := range l.Environments[envName] {
+			fmt.Fprintln(f, key)
+		}
+	}
+
+	return nil
+}
+
+// EnvironmentNames returns the sorted names of environments with their own recipients.
+func (l *List) EnvironmentNames() []string {
+	names := make([]string, 0, len(l.Environments))
+	for name := range l.Environments {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// For returns the keys that the named environment is encrypted to.
+func (l *List) For(envName string) []string {
+	for name := envName; ; {
+		if keys, ok := l.Environments[name]; ok {
+			return keys
+		}
+		// A nested environment such as production.worker uses the keys of its parent
+		dot := strings.LastIndex(name, ".")
+		if dot < 0 {
+			return l.Keys
+		}
+		name = name[:dot]
+	}
+}
+
+// Add appends a public key to the named environment, or to the default keys if envName is empty.
+// It reports false if the key was already present.
+func (l *List) Add(envName, key string) (bool, error) {
+	key = strings.TrimSpace(key)
+	if _, err := Parse(key); err != nil {
+		return false, err
+	}
+
+	keys := l.Keys
+	if envName != "" {
+		keys = l.Environments[envName]
+	}
+	for _, k := range keys {
+		if Same(k, key) {
+			return false, nil
+		}
+	}
+
+	if envName == "" {
+		l.Keys = append(l.Keys, key)
+	} else {
+		if l.Environments == nil {
+			l.Environments = make(map[string][]string)
+		}
+		l.Environments[envName] = append(l.Environments[envName], key)
+	}
+	return true, nil
+}
+
+// Remove deletes a public key from the named environment, or from the default keys if envName is empty.
+// It reports whether the key was present.
+func (l *List) Remove(envName, key string) bool {
+	key = strings.TrimSpace(key)
+
+	keys := l.Keys
+	if envName != "" {
+		keys = l.Environments[envName]
+	}
+	for i, k := range keys {
+		if Same(k, key) {
+			keys = append(keys[:i:i], keys[i+1:]...)
+			if envName == "" {
+				l.Keys = keys
+			} else {
+				l.Environments[envName] = keys
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// Replace swaps every occurrence of oldKey for newKey, in the default keys and in
+// every environment. It returns the number of lists that were changed.
+func (l *List) Replace(oldKey, newKey string) int {
+	replaced := 0
+	replaceIn := func(keys []string) {
+		for i, k := range keys {
+			if Same(k, oldKey) {
+				keys[i] = newKey
+				replaced++
+			}
+		}
+	}
+	replaceIn(l.Keys)
+	for _, keys := range l.Environments {
+		replaceIn(keys)
+	}
+	return replaced
+}
+
+// Recipients parses the keys that the named environment is encrypted to.
+func (l *List) Recipients(envName string) ([]age.Recipient, error) {
+	keys := l.For(envName)
+	result := make([]age.Recipient, 0, len(keys))
+	for _, key := range keys {
+		r, err := Parse(key)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, r)
+	}
+	return result, nil
+}
+
+// Parse parses a single public key: an age X25519 key, or an ssh-ed25519 or ssh-rsa
+// key in authorized_keys format.
+func Parse(key string) (age.Recipient, error) {
+	if strings.HasPrefix(key, "ssh-") {
+		r, err := agessh.ParseRecipient(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid recipient %q: %w", key, err)
+		}
+		return r, nil
+	}
+
+	r, err := age.ParseX25519Recipient(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient %q: %w", key, err)
+	}
+	return r, nil
+}
+
+// Same reports whether a and b are the same public key. SSH keys are compared
+// by type and key only, ignoring the trailing comment.
+func Same(a, b string) bool {
+	return normalize(a) == normalize(b)
+}
+
+// normalize strips the comment from an SSH public key.
+func normalize(key string) string {
+	fields := strings.Fields(key)
+	if len(fields) >= 2 && strings.HasPrefix(fields[0], "ssh-") {
+		return fields[0] + " " + fields[1]
+	}
+	return strings.TrimSpace(key)
+}
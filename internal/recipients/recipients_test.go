@@ -0,0 +1,290 @@
+package recipients
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"filippo.io/age"
+)
+
+func generateTestRecipient(t *testing.T) string {
+	t.Helper()
+	identity, err := age.GenerateX25519Identity()
+	if err != nil {
+		t.Fatalf("failed to generate identity: %v", err)
+	}
+	return identity.Recipient().String()
+}
+
+func TestParse(t *testing.T) {
+	const sshKey = "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHsKLqeplhpW+uObz5dvMgjz1OxfM/XXUB+VHtZ6isGN alice@example"
+
+	tests := []struct {
+		key   string
+		valid bool
+	}{
+		{generateTestRecipient(t), true},
+		{sshKey, true},
+		{"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHsKLqeplhpW+uObz5dvMgjz1OxfM/XXUB+VHtZ6isGN", true},
+		{"ssh-ed25519 invalid", false},
+		{"ssh-dss AAAAB3NzaC1kc3M=", false},
+		{"age1invalid", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			_, err := Parse(tt.key)
+			if (err == nil) != tt.valid {
+				t.Errorf("Parse(%q) error = %v, want valid = %v", tt.key, err, tt.valid)
+			}
+		})
+	}
+
+	t.Run("Same ignores SSH comments", func(t *testing.T) {
+		if !Same(sshKey, "ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIHsKLqeplhpW+uObz5dvMgjz1OxfM/XXUB+VHtZ6isGN bob@laptop") {
+			t.Error("Same() = false for keys differing only in comment")
+		}
+		if Same(sshKey, generateTestRecipient(t)) {
+			t.Error("Same() = true for different keys")
+		}
+	})
+}
+
+func TestPathFor(t *testing.T) {
+	tests := []struct {
+		secretsPath string
+		expected    string
+	}{
+		{"env.toml", DefaultFile},
+		{"services/api/env.toml", filepath.Join("services/api", DefaultFile)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.secretsPath, func(t *testing.T) {
+			if got := PathFor(tt.secretsPath); got != tt.expected {
+				t.Errorf("PathFor(%q) = %q, want %q", tt.secretsPath, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestAddAndRemove(t *testing.T) {
+	key1 := generateTestRecipient(t)
+	key2 := generateTestRecipient(t)
+
+	t.Run("adds new keys", func(t *testing.T) {
+		l := &List{}
+		added, err := l.Add("", key1)
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if !added {
+			t.Error("Add() = false, want true")
+		}
+		if len(l.Keys) != 1 {
+			t.Errorf("len(Keys) = %d, want 1", len(l.Keys))
+		}
+	})
+
+	t.Run("ignores duplicate keys", func(t *testing.T) {
+		l := &List{Keys: []string{key1}}
+		added, err := l.Add("", key1)
+		if err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if added {
+			t.Error("Add() of duplicate = true, want false")
+		}
+	})
+
+	t.Run("rejects invalid keys", func(t *testing.T) {
+		l := &List{}
+		if _, err := l.Add("", "not-a-key"); err == nil {
+			t.Error("Add() should have failed for invalid key")
+		}
+	})
+
+	t.Run("removes keys", func(t *testing.T) {
+		l := &List{Keys: []string{key1, key2}}
+		if !l.Remove("", key1) {
+			t.Error("Remove() = false, want true")
+		}
+		if len(l.Keys) != 1 || l.Keys[0] != key2 {
+			t.Errorf("Keys = %v, want [%s]", l.Keys, key2)
+		}
+		if l.Remove("", key1) {
+			t.Error("Remove() of missing key = true, want false")
+		}
+	})
+}
+
+func TestEnvironmentRecipients(t *testing.T) {
+	key1 := generateTestRecipient(t)
+	key2 := generateTestRecipient(t)
+
+	t.Run("For falls back to default keys", func(t *testing.T) {
+		l := &List{
+			Keys:         []string{key1, key2},
+			Environments: map[string][]string{"production": {key2}},
+		}
+
+		if got := l.For("development"); len(got) != 2 {
+			t.Errorf("For(development) = %v, want both keys", got)
+		}
+		if got := l.For("production"); len(got) != 1 || got[0] != key2 {
+			t.Errorf("For(production) = %v, want [%s]", got, key2)
+		}
+	})
+
+	t.Run("For uses the keys of a parent environment", func(t *testing.T) {
+		l := &List{
+			Keys:         []string{key1, key2},
+			Environments: map[string][]string{"production": {key2}},
+		}
+
+		if got := l.For("production.worker"); len(got) != 1 || got[0] != key2 {
+			t.Errorf("For(production.worker) = %v, want [%s]", got, key2)
+		}
+		if got := l.For("development.worker"); len(got) != 2 {
+			t.Errorf("For(development.worker) = %v, want both keys", got)
+		}
+	})
+
+	t.Run("Add and Remove only touch the named environment", func(t *testing.T) {
+		l := &List{Keys: []string{key1}}
+
+		if _, err := l.Add("production", key2); err != nil {
+			t.Fatalf("Add() error = %v", err)
+		}
+		if len(l.Keys) != 1 {
+			t.Errorf("default keys = %v, want [%s]", l.Keys, key1)
+		}
+		if got := l.For("production"); len(got) != 1 || got[0] != key2 {
+			t.Errorf("For(production) = %v, want [%s]", got, key2)
+		}
+
+		if l.Remove("production", key1) {
+			t.Error("Remove() of key not in environment = true, want false")
+		}
+		if !l.Remove("production", key2) {
+			t.Error("Remove() = false, want true")
+		}
+		if len(l.Keys) != 1 {
+			t.Error("default keys should be unchanged")
+		}
+	})
+}
+
+func TestReplace(t *testing.T) {
+	key1 := generateTestRecipient(t)
+	key2 := generateTestRecipient(t)
+	key3 := generateTestRecipient(t)
+
+	l := &List{
+		Keys:         []string{key1, key2},
+		Environments: map[string][]string{"production": {key1}, "staging": {key2}},
+	}
+
+	if n := l.Replace(key1, key3); n != 2 {
+		t.Errorf("Replace() = %d, want 2", n)
+	}
+	if l.Keys[0] != key3 || l.Keys[1] != key2 {
+		t.Errorf("Keys = %v, want [%s %s]", l.Keys, key3, key2)
+	}
+	if l.Environments["production"][0] != key3 {
+		t.Errorf("production = %v, want [%s]", l.Environments["production"], key3)
+	}
+	if l.Environments["staging"][0] != key2 {
+		t.Error("staging should be unchanged")
+	}
+}
+
+func TestLoadAndSave(t *testing.T) {
+	dir := t.TempDir()
+	key1 := generateTestRecipient(t)
+	key2 := generateTestRecipient(t)
+
+	t.Run("saves and loads file", func(t *testing.T) {
+		path := filepath.Join(dir, DefaultFile)
+		l := &List{Keys: []string{key1, key2}}
+
+		if err := l.Save(path); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if len(loaded.Keys) != 2 || loaded.Keys[0] != key1 || loaded.Keys[1] != key2 {
+			t.Errorf("Keys = %v, want [%s %s]", loaded.Keys, key1, key2)
+		}
+
+		recipients, err := loaded.Recipients("development")
+		if err != nil {
+			t.Fatalf("Recipients() error = %v", err)
+		}
+		if len(recipients) != 2 {
+			t.Errorf("len(Recipients()) = %d, want 2", len(recipients))
+		}
+	})
+
+	t.Run("saves and loads environment sections", func(t *testing.T) {
+		path := filepath.Join(dir, "sections")
+		l := &List{
+			Keys:         []string{key1, key2},
+			Environments: map[string][]string{"production": {key2}},
+		}
+
+		if err := l.Save(path); err != nil {
+			t.Fatalf("Save() error = %v", err)
+		}
+
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if len(loaded.Keys) != 2 {
+			t.Errorf("len(Keys) = %d, want 2", len(loaded.Keys))
+		}
+		if prod := loaded.Environments["production"]; len(prod) != 1 || prod[0] != key2 {
+			t.Errorf("production = %v, want [%s]", prod, key2)
+		}
+	})
+
+	t.Run("skips comments and blank lines", func(t *testing.T) {
+		path := filepath.Join(dir, "comments")
+		os.WriteFile(path, []byte("# team\n\n"+key1+"\n  # ops\n"), 0644)
+
+		loaded, err := Load(path)
+		if err != nil {
+			t.Fatalf("Load() error = %v", err)
+		}
+		if len(loaded.Keys) != 1 {
+			t.Errorf("len(Keys) = %d, want 1", len(loaded.Keys))
+		}
+	})
+
+	t.Run("Load fails for invalid key", func(t *testing.T) {
+		path := filepath.Join(dir, "invalid")
+		os.WriteFile(path, []byte("age1invalid\n"), 0644)
+
+		_, err := Load(path)
+		if err == nil {
+			t.Fatal("Load() should have failed for invalid key")
+		}
+		if !strings.Contains(err.Error(), "invalid recipient") {
+			t.Errorf("error = %v, want 'invalid recipient' message", err)
+		}
+	})
+
+	t.Run("Load fails for non-existent file", func(t *testing.T) {
+		_, err := Load(filepath.Join(dir, "missing"))
+		if !errors.Is(err, os.ErrNotExist) {
+			t.Errorf("error = %v, want not-exist error", err)
+		}
+	})
+}
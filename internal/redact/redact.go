@@ -0,0 +1,101 @@
+package redact
+
+import (
+	"bytes"
+	"io"
+	"sort"
+)
+
+// DefaultMinLength is the length below which values are not redacted, as short values
+// such as "1" or "true" would mask unrelated output.
+const DefaultMinLength = 6
+
+type secret struct {
+	value       []byte
+	replacement []byte
+}
+
+// Writer replaces every secret value written to it with ***KEY*** and writes the result
+// to the underlying writer. Output that may be the start of a secret is held back until
+// the next write shows whether it is, so call Flush once all output is written.
+type Writer struct {
+	w       io.Writer
+	secrets map[byte][]secret
+	held    []byte
+}
+
+// NewWriter returns a Writer that redacts the values at least minLength bytes long. When
+// several keys share a value, the first key in sorted order is used.
+func NewWriter(w io.Writer, values map[string]string, minLength int) *Writer {
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	seen := make(map[string]bool)
+	r := &Writer{w: w, secrets: make(map[byte][]secret)}
+	for _, key := range keys {
+		value := values[key]
+		if len(value) == 0 || len(value) < minLength || seen[value] {
+			continue
+		}
+		seen[value] = true
+		s := secret{value: []byte(value), replacement: []byte("***" + key + "***")}
+		r.secrets[value[0]] = append(r.secrets[value[0]], s)
+	}
+
+	// Try the longest value first, so that a secret containing another one is masked whole
+	for _, list := range r.secrets {
+		sort.SliceStable(list, func(i, j int) bool {
+			return len(list[i].value) > len(list[j].value)
+		})
+	}
+	return r
+}
+
+// Write redacts p and writes it, except for a trailing part that may be the start of a secret.
+func (r *Writer) Write(p []byte) (int, error) {
+	r.held = append(r.held, p...)
+	if err := r.process(false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Flush redacts and writes everything that is held back.
+func (r *Writer) Flush() error {
+	return r.process(true)
+}
+
+// process writes the redacted contents of the held buffer. Unless final, it stops at the
+// first position where a secret may start but the buffer ends before it is complete.
+func (r *Writer) process(final bool) error {
+	buf := r.held
+	var out bytes.Buffer
+	i := 0
+
+scan:
+	for i < len(buf) {
+		for _, s := range r.secrets[buf[i]] {
+			rest := buf[i:]
+			if bytes.HasPrefix(rest, s.value) {
+				out.Write(s.replacement)
+				i += len(s.value)
+				continue scan
+			}
+			if !final && len(rest) < len(s.value) && bytes.HasPrefix(s.value, rest) {
+				break scan
+			}
+		}
+		out.WriteByte(buf[i])
+		i++
+	}
+
+	r.held = append(r.held[:0], buf[i:]...)
+	if out.Len() == 0 {
+		return nil
+	}
+	_, err := r.w.Write(out.Bytes())
+	return err
+}
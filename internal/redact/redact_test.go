@@ -0,0 +1,88 @@
+package redact
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+var testValues = map[string]string{
+	"API_KEY":  "sk_live_abc123",
+	"PASSWORD": "hunter22",
+	"TOKEN":    "hunter22-extra",
+	"PORT":     "5432",
+	"EMPTY":    "",
+	"CERT":     "-----BEGIN-----\nsecret\n-----END-----",
+}
+
+func redact(t *testing.T, input string, chunk int) string {
+	t.Helper()
+	var buf bytes.Buffer
+	w := NewWriter(&buf, testValues, DefaultMinLength)
+	for len(input) > 0 {
+		n := chunk
+		if n > len(input) {
+			n = len(input)
+		}
+		if _, err := w.Write([]byte(input[:n])); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+		input = input[n:]
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	return buf.String()
+}
+
+func TestWriter(t *testing.T) {
+	input := "key=sk_live_abc123 pw=hunter22 token=hunter22-extra port=5432\n-----BEGIN-----\nsecret\n-----END-----\nhunter2 sk_live_abc"
+	want := "key=***API_KEY*** pw=***PASSWORD*** token=***TOKEN*** port=5432\n***CERT***\nhunter2 sk_live_abc"
+
+	t.Run("replaces values", func(t *testing.T) {
+		if got := redact(t, input, len(input)); got != want {
+			t.Errorf("got\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("handles values split across writes", func(t *testing.T) {
+		for _, chunk := range []int{1, 2, 3, 5, 7, 13} {
+			if got := redact(t, input, chunk); got != want {
+				t.Errorf("chunk %d: got\n%s\nwant\n%s", chunk, got, want)
+			}
+		}
+	})
+
+	t.Run("writes what cannot be a secret right away", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, testValues, DefaultMinLength)
+		w.Write([]byte("hello hunter"))
+		if got := buf.String(); got != "hello " {
+			t.Errorf("written = %q, want %q", got, "hello ")
+		}
+		w.Write([]byte("s\n"))
+		if got := buf.String(); got != "hello hunters\n" {
+			t.Errorf("written = %q, want %q", got, "hello hunters\n")
+		}
+	})
+
+	t.Run("honors the minimum length", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, testValues, 1)
+		w.Write([]byte("port=5432"))
+		w.Flush()
+		if got := buf.String(); got != "port=***PORT***" {
+			t.Errorf("got %q", got)
+		}
+	})
+
+	t.Run("uses the first key for shared values", func(t *testing.T) {
+		var buf bytes.Buffer
+		w := NewWriter(&buf, map[string]string{"B": "shared-value", "A": "shared-value"}, DefaultMinLength)
+		w.Write([]byte("shared-value"))
+		w.Flush()
+		if got := buf.String(); !strings.Contains(got, "***A***") {
+			t.Errorf("got %q", got)
+		}
+	})
+}
@@ -0,0 +1,518 @@
+package secrets
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// layout records what the Environments map can't hold: comments, blank lines, the order of
+// sections and keys, and how each value was written. Save uses it to write env.toml back the
+// way it was read, so only the values that changed show up in a diff.
+type layout struct {
+	preamble []string // comments and blank lines before the first section
+	sections []*sectionLayout
+	footer   []string // comments and blank lines after the last value
+}
+
+type sectionLayout struct {
+	name     string
+	comments []string // comments and blank lines before the header
+	header   string   // the header line as written
+	entries  []*entryLayout
+}
+
+type entryLayout struct {
+	key      string
+	comments []string // comments and blank lines before the key
+	prefix   string   // everything up to the value, e.g. "API_KEY = "
+	raw      string   // the value as written
+	value    string   // the value as parsed
+	literal  bool     // the value is not a string, e.g. PORT = 3000
+	trailing string   // anything after the value, e.g. "  # owner: ops"
+}
+
+var bareKey = regexp.MustCompile(`^[A-Za-z0-9_-]+$`)
+
+// parseLayout scans env.toml line by line. The data must already be valid TOML.
+func parseLayout(data string) (*layout, error) {
+	l := &layout{}
+	var pending []string
+	var section *sectionLayout
+
+	pos, lineNum := 0, 1
+	for pos < len(data) {
+		eol := strings.IndexByte(data[pos:], '\n')
+		if eol < 0 {
+			eol = len(data)
+		} else {
+			eol += pos
+		}
+		line := data[pos:eol]
+		trimmed := strings.TrimSpace(line)
+
+		switch {
+		case trimmed == "" || strings.HasPrefix(trimmed, "#"):
+			pending = append(pending, line)
+
+		case strings.HasPrefix(trimmed, "[["):
+			return nil, fmt.Errorf("line %d: arrays of tables are not supported", lineNum)
+
+		case strings.HasPrefix(trimmed, "["):
+			name, err := parseHeader(trimmed)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if section == nil {
+				// Comments directly above the first header belong to it, anything before is the preamble
+				split := 0
+				for i, p := range pending {
+					if strings.TrimSpace(p) == "" {
+						split = i + 1
+					}
+				}
+				l.preamble, pending = pending[:split], pending[split:]
+			}
+			section = &sectionLayout{name: name, comments: pending, header: line}
+			l.sections = append(l.sections, section)
+			pending = nil
+
+		default:
+			if section == nil {
+				return nil, fmt.Errorf("line %d: %s is outside of an environment section", lineNum, trimmed)
+			}
+
+			eq := indexOutsideQuotes(line, '=')
+			if eq < 0 {
+				return nil, fmt.Errorf("line %d: expected KEY = value", lineNum)
+			}
+			segments, err := splitKey(line[:eq])
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			if len(segments) != 1 {
+				return nil, fmt.Errorf("line %d: dotted keys are not supported, use a [section] instead", lineNum)
+			}
+
+			start := eq + 1
+			for start < len(line) && (line[start] == ' ' || line[start] == '\t') {
+				start++
+			}
+			end, err := scanValue(data, pos+start)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %w", lineNum, err)
+			}
+			raw := strings.TrimRight(data[pos+start:end], " \t")
+			end = pos + start + len(raw)
+
+			// The rest of the value's last line may only hold a comment
+			eol = strings.IndexByte(data[end:], '\n')
+			if eol < 0 {
+				eol = len(data)
+			} else {
+				eol += end
+			}
+			trailing := data[end:eol]
+			if t := strings.TrimSpace(trailing); t != "" && !strings.HasPrefix(t, "#") {
+				return nil, fmt.Errorf("line %d: unexpected %q after value", lineNum, t)
+			}
+
+			if strings.HasPrefix(raw, "{") {
+				return nil, fmt.Errorf("line %d: inline tables are not supported, use a [%s.%s] section instead", lineNum, quoteName(section.name), QuoteKey(segments[0]))
+			}
+
+			section.entries = append(section.entries, &entryLayout{
+				key:      segments[0],
+				comments: pending,
+				prefix:   line[:start],
+				raw:      raw,
+				literal:  !strings.HasPrefix(raw, `"`) && !strings.HasPrefix(raw, "'"),
+				trailing: trailing,
+			})
+			pending = nil
+			lineNum += strings.Count(raw, "\n")
+		}
+
+		pos = eol + 1
+		lineNum++
+	}
+
+	l.footer = pending
+	return l, nil
+}
+
+// parseHeader returns the name of a [section] header, with dotted parts joined by dots.
+func parseHeader(line string) (string, error) {
+	end := indexOutsideQuotes(line, ']')
+	if end < 0 {
+		return "", fmt.Errorf("unterminated section header")
+	}
+	if rest := strings.TrimSpace(line[end+1:]); rest != "" && !strings.HasPrefix(rest, "#") {
+		return "", fmt.Errorf("unexpected %q after section header", rest)
+	}
+	segments, err := splitKey(line[1:end])
+	if err != nil {
+		return "", err
+	}
+	return strings.Join(segments, "."), nil
+}
+
+// splitKey splits a possibly dotted and quoted TOML key into its parts.
+func splitKey(key string) ([]string, error) {
+	var segments []string
+	for {
+		key = strings.TrimSpace(key)
+		dot := indexOutsideQuotes(key, '.')
+		part := key
+		if dot >= 0 {
+			part = strings.TrimSpace(key[:dot])
+		}
+
+		switch {
+		case strings.HasPrefix(part, `"`):
+			s, err := strconv.Unquote(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid key %s", part)
+			}
+			segments = append(segments, s)
+		case strings.HasPrefix(part, "'") && strings.HasSuffix(part, "'") && len(part) >= 2:
+			segments = append(segments, part[1:len(part)-1])
+		case bareKey.MatchString(part):
+			segments = append(segments, part)
+		default:
+			return nil, fmt.Errorf("invalid key %q", part)
+		}
+
+		if dot < 0 {
+			return segments, nil
+		}
+		key = key[dot+1:]
+	}
+}
+
+// indexOutsideQuotes returns the index of the first c in s that is not inside a quoted string.
+func indexOutsideQuotes(s string, c byte) int {
+	var quote byte
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quote == '"' && s[i] == '\\':
+			i++
+		case quote != 0:
+			if s[i] == quote {
+				quote = 0
+			}
+		case s[i] == '"' || s[i] == '\'':
+			quote = s[i]
+		case s[i] == c:
+			return i
+		}
+	}
+	return -1
+}
+
+// scanValue returns the index just past the TOML value that starts at pos. Strings, arrays
+// and inline tables may span lines; anything else ends at a comment or the end of the line.
+func scanValue(s string, pos int) (int, error) {
+	switch {
+	case strings.HasPrefix(s[pos:], `"""`), strings.HasPrefix(s[pos:], `'''`):
+		delim := s[pos : pos+3]
+		for i := pos + 3; i < len(s); i++ {
+			if delim == `"""` && s[i] == '\\' {
+				i++
+				continue
+			}
+			if strings.HasPrefix(s[i:], delim) {
+				// Up to two quotes may directly precede the closing delimiter
+				end := i + 3
+				for end < len(s) && end < i+5 && s[end] == delim[0] {
+					end++
+				}
+				return end, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated multi-line string")
+
+	case s[pos] == '"' || s[pos] == '\'':
+		for i := pos + 1; i < len(s) && s[i] != '\n'; i++ {
+			if s[pos] == '"' && s[i] == '\\' {
+				i++
+				continue
+			}
+			if s[i] == s[pos] {
+				return i + 1, nil
+			}
+		}
+		return 0, fmt.Errorf("unterminated string")
+
+	case s[pos] == '[' || s[pos] == '{':
+		depth := 0
+		for i := pos; i < len(s); i++ {
+			switch s[i] {
+			case '[', '{':
+				depth++
+			case ']', '}':
+				depth--
+				if depth == 0 {
+					return i + 1, nil
+				}
+			case '#':
+				// Comments are allowed between array elements
+				for i < len(s) && s[i] != '\n' {
+					i++
+				}
+			case '"', '\'':
+				end, err := scanValue(s, i)
+				if err != nil {
+					return 0, err
+				}
+				i = end - 1
+			}
+		}
+		return 0, fmt.Errorf("unterminated array")
+
+	default:
+		end := pos
+		for end < len(s) && s[end] != '\n' && s[end] != '#' {
+			end++
+		}
+		return end, nil
+	}
+}
+
+// encode writes the file, following the layout it was read with. Sections and keys that
+// were added since are appended in sorted order, and removed ones are left out along with
+// their comments.
+func (f *File) encode() []byte {
+	l := f.layout
+	if l == nil {
+		l = &layout{}
+	}
+
+	var buf bytes.Buffer
+	writeLines(&buf, l.preamble)
+
+	written := make(map[string]bool)
+	for _, section := range l.sections {
+		env, ok := f.Environments[section.name]
+		if !ok || written[section.name] {
+			continue
+		}
+		written[section.name] = true
+
+		writeLines(&buf, section.comments)
+		buf.WriteString(section.header + "\n")
+
+		seen := make(map[string]bool)
+		if !section.has(InheritsKey) {
+			f.writeInherits(&buf, section.name)
+		}
+		for _, entry := range section.entries {
+			value, ok := env[entry.key]
+			if entry.key == InheritsKey {
+				value, ok = f.Inherits[section.name]
+				ok = ok && value != ""
+			}
+			if !ok || seen[entry.key] {
+				continue
+			}
+			seen[entry.key] = true
+
+			writeLines(&buf, entry.comments)
+			raw := entry.raw
+			if value != entry.value {
+				raw = quote(value)
+			}
+			buf.WriteString(entry.prefix + raw + entry.trailing + "\n")
+		}
+		writeEntries(&buf, env, seen)
+	}
+	writeLines(&buf, l.footer)
+
+	// Append new sections
+	var names []string
+	for name := range f.Environments {
+		if !written[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+			buf.WriteString("\n")
+		}
+		fmt.Fprintf(&buf, "[%s]\n", quoteName(name))
+		f.writeInherits(&buf, name)
+		writeEntries(&buf, f.Environments[name], nil)
+	}
+
+	return buf.Bytes()
+}
+
+// writeInherits writes the inherits line of a section, if it has one.
+func (f *File) writeInherits(buf *bytes.Buffer, name string) {
+	if parent := f.Inherits[name]; parent != "" {
+		fmt.Fprintf(buf, "%s = %s\n", InheritsKey, quote(parent))
+	}
+}
+
+// has reports whether the section was read with the key.
+func (s *sectionLayout) has(key string) bool {
+	for _, entry := range s.entries {
+		if entry.key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// writeEntries writes the keys of env that are not in seen, sorted.
+func writeEntries(buf *bytes.Buffer, env map[string]string, seen map[string]bool) {
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		if !seen[k] {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(buf, "%s = %s\n", QuoteKey(key), quote(env[key]))
+	}
+}
+
+// writeLines writes comments and blank lines. Leading blank lines are skipped at the start
+// of the file or after another blank line, so removing a section doesn't leave a gap.
+func writeLines(buf *bytes.Buffer, lines []string) {
+	for _, line := range lines {
+		if strings.TrimSpace(line) == "" && (buf.Len() == 0 || bytes.HasSuffix(buf.Bytes(), []byte("\n\n"))) {
+			continue
+		}
+		buf.WriteString(line + "\n")
+	}
+}
+
+// quote returns s as a TOML basic string.
+func quote(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"':
+			b.WriteString(`\"`)
+		case '\\':
+			b.WriteString(`\\`)
+		case '\b':
+			b.WriteString(`\b`)
+		case '\t':
+			b.WriteString(`\t`)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\f':
+			b.WriteString(`\f`)
+		case '\r':
+			b.WriteString(`\r`)
+		default:
+			if r < 0x20 || r == 0x7f {
+				fmt.Fprintf(&b, `\u%04X`, r)
+			} else {
+				b.WriteRune(r)
+			}
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// QuoteKey returns key bare if TOML allows it, quoted otherwise, as it is written in env.toml.
+func QuoteKey(key string) string {
+	if bareKey.MatchString(key) {
+		return key
+	}
+	return quote(key)
+}
+
+// quoteName returns a dotted section name with each part quoted as needed.
+func quoteName(name string) string {
+	parts := strings.Split(name, ".")
+	for i, part := range parts {
+		parts[i] = QuoteKey(part)
+	}
+	return strings.Join(parts, ".")
+}
+
+// KeepLiterals puts the non-string values of an environment, such as PORT = 3000, back into
+// env after it has been encrypted. Those values stay plaintext; quote a value to encrypt it.
+func (f *File) KeepLiterals(envName string, env map[string]string) {
+	if f.layout == nil {
+		return
+	}
+	for _, section := range f.layout.sections {
+		if section.name != envName {
+			continue
+		}
+		for _, entry := range section.entries {
+			if _, ok := env[entry.key]; ok && entry.literal {
+				env[entry.key] = entry.value
+			}
+		}
+	}
+}
+
+// FormatValue returns a value of the named environment as TOML. A non-string value such as
+// PORT = 3000 is returned as written in the file, anything else as a quoted string.
+func (f *File) FormatValue(envName, key, value string) string {
+	if f.layout != nil {
+		for _, section := range f.layout.sections {
+			if section.name != envName {
+				continue
+			}
+			for _, entry := range section.entries {
+				if entry.key == key && entry.literal && entry.value == value {
+					return entry.raw
+				}
+			}
+		}
+	}
+	return quote(value)
+}
+
+// WithEnvironments returns a copy of the file with different values but the same layout,
+// such as the decrypted values for editing.
+func (f *File) WithEnvironments(envs map[string]map[string]string) *File {
+	return &File{Environments: envs, Inherits: f.Inherits, layout: f.layout}
+}
+
+// CopyEnvironment copies an environment from src, along with its comments.
+func (f *File) CopyEnvironment(src *File, name string) {
+	env, ok := src.Environments[name]
+	if !ok {
+		return
+	}
+	if f.Environments == nil {
+		f.Environments = make(map[string]map[string]string)
+	}
+	f.Environments[name] = env
+	if parent := src.Inherits[name]; parent != "" {
+		if f.Inherits == nil {
+			f.Inherits = make(map[string]string)
+		}
+		f.Inherits[name] = parent
+	}
+
+	if src.layout == nil {
+		return
+	}
+	for _, section := range src.layout.sections {
+		if section.name == name {
+			if f.layout == nil {
+				f.layout = &layout{}
+			}
+			f.layout.sections = append(f.layout.sections, section)
+			return
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+)
+
+const commentedFile = `# Secrets for myapp
+
+[development]
+API_KEY = "dev-key"
+
+# Live keys
+[production] # prod
+# Stripe: https://dashboard.stripe.com
+API_KEY = "prod-key"
+DB_URL  =  'postgres://db'  # owner: ops
+NOTES = """
+line one
+line two"""
+`
+
+func parseTest(t *testing.T, data string) *File {
+	t.Helper()
+	f, err := Parse([]byte(data))
+	if err != nil {
+		t.Fatalf("Parse() error = %v", err)
+	}
+	return f
+}
+
+func TestParseLayout(t *testing.T) {
+	t.Run("writes an unchanged file back as is", func(t *testing.T) {
+		f := parseTest(t, commentedFile)
+
+		if got := string(f.Bytes()); got != commentedFile {
+			t.Errorf("Bytes() =\n%s\nwant\n%s", got, commentedFile)
+		}
+		if f.Environments["production"]["NOTES"] != "line one\nline two" {
+			t.Errorf("NOTES = %q", f.Environments["production"]["NOTES"])
+		}
+	})
+
+	t.Run("keeps comments of changed values", func(t *testing.T) {
+		f := parseTest(t, commentedFile)
+		f.Set("production", "DB_URL", "postgres://new")
+
+		got := string(f.Bytes())
+		if !strings.Contains(got, `DB_URL  =  "postgres://new"  # owner: ops`) {
+			t.Errorf("changed value lost its formatting:\n%s", got)
+		}
+		if !strings.Contains(got, "# Stripe: https://dashboard.stripe.com\nAPI_KEY") {
+			t.Errorf("comment lost:\n%s", got)
+		}
+	})
+
+	t.Run("appends new keys to their section", func(t *testing.T) {
+		f := parseTest(t, commentedFile)
+		f.Set("development", "B_KEY", "b")
+		f.Set("development", "A_KEY", "a")
+
+		got := string(f.Bytes())
+		want := "[development]\nAPI_KEY = \"dev-key\"\nA_KEY = \"a\"\nB_KEY = \"b\"\n\n# Live keys"
+		if !strings.Contains(got, want) {
+			t.Errorf("Bytes() =\n%s\nwant it to contain\n%s", got, want)
+		}
+	})
+
+	t.Run("drops the comments of removed keys and sections", func(t *testing.T) {
+		f := parseTest(t, commentedFile)
+		f.Unset("production", "API_KEY")
+		delete(f.Environments, "development")
+
+		got := string(f.Bytes())
+		if strings.Contains(got, "Stripe") || strings.Contains(got, "dev-key") {
+			t.Errorf("removed entries still present:\n%s", got)
+		}
+		if !strings.HasPrefix(got, "# Secrets for myapp\n\n# Live keys\n[production] # prod\n") {
+			t.Errorf("Bytes() =\n%s", got)
+		}
+	})
+
+	t.Run("appends new sections", func(t *testing.T) {
+		f := parseTest(t, commentedFile)
+		f.Set("staging", "API_KEY", "staging-key")
+
+		got := string(f.Bytes())
+		if !strings.HasSuffix(got, "line two\"\"\"\n\n[staging]\nAPI_KEY = \"staging-key\"\n") {
+			t.Errorf("Bytes() =\n%s", got)
+		}
+	})
+
+	t.Run("keeps the layout with other values", func(t *testing.T) {
+		f := parseTest(t, commentedFile)
+		view := f.WithEnvironments(map[string]map[string]string{
+			"production": {"API_KEY": "decrypted"},
+		})
+
+		got := string(view.Bytes())
+		want := "# Secrets for myapp\n\n# Live keys\n[production] # prod\n# Stripe: https://dashboard.stripe.com\nAPI_KEY = \"decrypted\"\n"
+		if got != want {
+			t.Errorf("Bytes() =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("copies an environment with its comments", func(t *testing.T) {
+		src := parseTest(t, commentedFile)
+		f := parseTest(t, "[development]\nA = \"a\"\n")
+		f.CopyEnvironment(src, "production")
+
+		got := string(f.Bytes())
+		if !strings.Contains(got, "# Live keys\n[production] # prod\n# Stripe") {
+			t.Errorf("Bytes() =\n%s", got)
+		}
+	})
+
+	t.Run("escapes new values as TOML strings", func(t *testing.T) {
+		f := &File{}
+		f.Set("development", "KEY", "tab\there \"quoted\" \x00")
+
+		loaded := parseTest(t, string(f.Bytes()))
+		if got := loaded.Environments["development"]["KEY"]; got != "tab\there \"quoted\" \x00" {
+			t.Errorf("KEY = %q", got)
+		}
+	})
+
+	t.Run("formats literals as written", func(t *testing.T) {
+		f := parseTest(t, "[development]\nPORT = 3000\nHOSTS = [\"a\", \"b\"]\nNAME = \"app\"\n")
+		tests := []struct{ key, value, want string }{
+			{"PORT", "3000", "3000"},
+			{"HOSTS", "a,b", `["a", "b"]`},
+			{"NAME", "app", `"app"`},
+			{"PORT", "4000", `"4000"`},
+			{"OTHER", "x\ny", `"x\ny"`},
+		}
+		for _, tt := range tests {
+			if got := f.FormatValue("development", tt.key, tt.value); got != tt.want {
+				t.Errorf("FormatValue(%s, %q) = %s, want %s", tt.key, tt.value, got, tt.want)
+			}
+		}
+	})
+
+	t.Run("rejects what it cannot round-trip", func(t *testing.T) {
+		tests := map[string]string{
+			"dotted key":      "[development]\na.b = \"x\"\n",
+			"key outside":     "KEY = \"x\"\n",
+			"array of tables": "[[development]]\nKEY = \"x\"\n",
+		}
+		for name, data := range tests {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Errorf("%s: Parse() should have failed", name)
+			}
+		}
+	})
+}
@@ -0,0 +1,94 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// InheritsKey is the key that names the environment a section inherits from.
+const InheritsKey = "inherits"
+
+// BaseEnvironments are sections whose values apply to every other environment, in the
+// order they are applied.
+var BaseEnvironments = []string{"default", "shared"}
+
+// IsBase reports whether name is a base section such as [shared].
+func IsBase(name string) bool {
+	for _, base := range BaseEnvironments {
+		if name == base {
+			return true
+		}
+	}
+	return false
+}
+
+// Layers returns the sections that make up an environment, base first and the environment
+// itself last. An environment builds on the one it inherits from, or else on its parent if
+// it is nested like production.worker, or else on the base sections.
+func (f *File) Layers(name string) ([]string, error) {
+	return f.layers(name, nil)
+}
+
+func (f *File) layers(name string, visiting []string) ([]string, error) {
+	if _, ok := f.Environments[name]; !ok {
+		return nil, fmt.Errorf("environment %q not found", name)
+	}
+	for _, v := range visiting {
+		if v == name {
+			return nil, fmt.Errorf("inheritance cycle: %s", strings.Join(append(visiting, name), " -> "))
+		}
+	}
+	visiting = append(visiting, name)
+
+	var base []string
+	if parent := f.Inherits[name]; parent != "" {
+		if _, ok := f.Environments[parent]; !ok {
+			return nil, fmt.Errorf("environment %q inherits from unknown environment %q", name, parent)
+		}
+		layers, err := f.layers(parent, visiting)
+		if err != nil {
+			return nil, err
+		}
+		base = layers
+	} else if parent := f.nestedParent(name); parent != "" {
+		layers, err := f.layers(parent, visiting)
+		if err != nil {
+			return nil, err
+		}
+		base = layers
+	} else if !IsBase(name) {
+		for _, b := range BaseEnvironments {
+			if _, ok := f.Environments[b]; ok {
+				base = append(base, b)
+			}
+		}
+	}
+
+	return append(base, name), nil
+}
+
+// nestedParent returns the nearest existing environment that contains a nested one,
+// such as production for production.worker.
+func (f *File) nestedParent(name string) string {
+	for dot := strings.LastIndex(name, "."); dot >= 0; dot = strings.LastIndex(name, ".") {
+		name = name[:dot]
+		if _, ok := f.Environments[name]; ok {
+			return name
+		}
+	}
+	return ""
+}
+
+// Merge combines the values of layers from envs, later layers overriding earlier ones.
+// It also returns the layer that each value came from.
+func Merge(layers []string, envs map[string]map[string]string) (map[string]string, map[string]string) {
+	values := make(map[string]string)
+	sources := make(map[string]string)
+	for _, layer := range layers {
+		for k, v := range envs[layer] {
+			values[k] = v
+			sources[k] = layer
+		}
+	}
+	return values, sources
+}
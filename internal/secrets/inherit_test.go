@@ -0,0 +1,114 @@
+package secrets
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const inheritingFile = `[shared]
+REGION = "us-east-1"
+
+[production]
+HOST = "prod.example.com"
+SECRET = "prod"
+
+[staging]
+inherits = "production"
+SECRET = "staging"
+
+[staging.worker]
+QUEUE = "jobs"
+
+[development]
+SECRET = "dev"
+`
+
+func TestLayers(t *testing.T) {
+	f := parseTest(t, inheritingFile)
+
+	tests := map[string][]string{
+		"shared":         {"shared"},
+		"production":     {"shared", "production"},
+		"staging":        {"shared", "production", "staging"},
+		"staging.worker": {"shared", "production", "staging", "staging.worker"},
+	}
+	for name, want := range tests {
+		got, err := f.Layers(name)
+		if err != nil {
+			t.Fatalf("Layers(%q) error = %v", name, err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("Layers(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	t.Run("applies default before shared", func(t *testing.T) {
+		f := parseTest(t, "[shared]\n[default]\n[development]\n")
+		got, _ := f.Layers("development")
+		if want := []string{"default", "shared", "development"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Layers() = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestInheritance(t *testing.T) {
+	t.Run("GetEnvironment resolves inherited values", func(t *testing.T) {
+		f := parseTest(t, inheritingFile)
+
+		env, err := f.GetEnvironment("staging")
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		want := map[string]string{"REGION": "us-east-1", "HOST": "prod.example.com", "SECRET": "staging"}
+		if !reflect.DeepEqual(env, want) {
+			t.Errorf("staging = %v, want %v", env, want)
+		}
+	})
+
+	t.Run("inherits is not a value", func(t *testing.T) {
+		f := parseTest(t, inheritingFile)
+
+		if _, ok := f.Environments["staging"][InheritsKey]; ok {
+			t.Error("inherits should not be in the environment")
+		}
+		if f.Inherits["staging"] != "production" {
+			t.Errorf("Inherits[staging] = %q, want production", f.Inherits["staging"])
+		}
+	})
+
+	t.Run("writes inherits back", func(t *testing.T) {
+		f := parseTest(t, inheritingFile)
+		if got := string(f.Bytes()); got != inheritingFile {
+			t.Errorf("Bytes() =\n%s\nwant\n%s", got, inheritingFile)
+		}
+
+		f.Inherits["development"] = "staging"
+		if got := string(f.Bytes()); !strings.Contains(got, "[development]\ninherits = \"staging\"\nSECRET") {
+			t.Errorf("Bytes() =\n%s", got)
+		}
+	})
+
+	t.Run("Merge reports where values come from", func(t *testing.T) {
+		f := parseTest(t, inheritingFile)
+		layers, _ := f.Layers("staging")
+
+		_, sources := Merge(layers, f.Environments)
+		want := map[string]string{"REGION": "shared", "HOST": "production", "SECRET": "staging"}
+		if !reflect.DeepEqual(sources, want) {
+			t.Errorf("sources = %v, want %v", sources, want)
+		}
+	})
+
+	t.Run("rejects unknown parents and cycles", func(t *testing.T) {
+		tests := map[string]string{
+			"unknown": "[staging]\ninherits = \"nope\"\n",
+			"cycle":   "[a]\ninherits = \"b\"\n\n[b]\ninherits = \"a\"\n",
+		}
+		for name, data := range tests {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Errorf("%s: Parse() should have failed", name)
+			}
+		}
+	})
+}
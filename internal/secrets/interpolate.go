@@ -0,0 +1,118 @@
+package secrets
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Interpolate expands references in the decrypted values of an environment. ${KEY} refers
+// to another key of the same environment and ${env.KEY} to a key of another environment,
+// whose decrypted values lookup returns. $${ is a literal ${.
+func Interpolate(envName string, env map[string]string, lookup func(envName string) (map[string]string, error)) (map[string]string, error) {
+	in := &interpolator{
+		envs:     map[string]map[string]string{envName: env},
+		lookup:   lookup,
+		expanded: make(map[string]string),
+	}
+
+	result := make(map[string]string, len(env))
+	for key := range env {
+		value, err := in.expand(envName, key, nil)
+		if err != nil {
+			return nil, err
+		}
+		result[key] = value
+	}
+	return result, nil
+}
+
+type interpolator struct {
+	envs     map[string]map[string]string
+	lookup   func(envName string) (map[string]string, error)
+	expanded map[string]string // by env.KEY
+}
+
+// expand returns the value of key with its references expanded. stack holds the references
+// being expanded, to detect cycles.
+func (in *interpolator) expand(envName, key string, stack []string) (string, error) {
+	id := envName + "." + key
+	if value, ok := in.expanded[id]; ok {
+		return value, nil
+	}
+	for i, s := range stack {
+		if s == id {
+			return "", fmt.Errorf("reference cycle: %s", strings.Join(append(stack[i:], id), " -> "))
+		}
+	}
+	stack = append(stack, id)
+
+	env, err := in.env(envName)
+	if err != nil {
+		return "", err
+	}
+	value := env[key]
+
+	var b strings.Builder
+	for i := 0; i < len(value); {
+		switch {
+		case strings.HasPrefix(value[i:], "$${"):
+			b.WriteString("${")
+			i += 3
+
+		case strings.HasPrefix(value[i:], "${"):
+			end := strings.IndexByte(value[i+2:], '}')
+			if end < 0 {
+				return "", fmt.Errorf("%s: unterminated ${ (use $${ for a literal ${)", id)
+			}
+			ref := value[i+2 : i+2+end]
+
+			refEnv, refKey := envName, ref
+			if dot := strings.LastIndex(ref, "."); dot >= 0 {
+				refEnv, refKey = ref[:dot], ref[dot+1:]
+			}
+			if refEnv == "" || refKey == "" {
+				return "", fmt.Errorf("%s: invalid reference ${%s}", id, ref)
+			}
+
+			refValues, err := in.env(refEnv)
+			if err != nil {
+				return "", fmt.Errorf("%s: ${%s}: %w", id, ref, err)
+			}
+			if _, ok := refValues[refKey]; !ok {
+				return "", fmt.Errorf("%s: ${%s} is not set", id, ref)
+			}
+
+			expanded, err := in.expand(refEnv, refKey, stack)
+			if err != nil {
+				return "", err
+			}
+			b.WriteString(expanded)
+			i += end + 3
+
+		default:
+			b.WriteByte(value[i])
+			i++
+		}
+	}
+
+	in.expanded[id] = b.String()
+	return b.String(), nil
+}
+
+// env returns the decrypted values of an environment, looking it up the first time.
+func (in *interpolator) env(name string) (map[string]string, error) {
+	if env, ok := in.envs[name]; ok {
+		return env, nil
+	}
+	env, err := in.lookup(name)
+	if err != nil {
+		return nil, err
+	}
+	in.envs[name] = env
+	return env, nil
+}
+
+// EscapeReferences returns value with every ${ written as $${, so that Interpolate leaves it as is.
+func EscapeReferences(value string) string {
+	return strings.ReplaceAll(value, "${", "$${")
+}
@@ -2,8 +2,11 @@ package secrets
 
 import (
 	"encoding/base64"
+	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
+	"regexp"
 	"sort"
 	"strings"
 
@@ -17,11 +20,42 @@ const (
 	DefaultEnvironment = "development"
 	EncryptedPrefix    = "ENC["
 	EncryptedSuffix    = "]"
+	FileEnvVar         = "SSE_FILE"
+	EnvironmentEnvVar  = "SSE_ENV"
+	StrictEnvVar       = "SSE_STRICT"
 )
 
+// LocalPathFor returns the path of the local override file that sits next to the given
+// secrets file, e.g. env.local.toml for env.toml.
+func LocalPathFor(path string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + ".local" + ext
+}
+
+var keyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// ValidKey reports whether key can be used as an environment variable name: letters,
+// digits and underscores, not starting with a digit.
+func ValidKey(key string) bool {
+	return keyPattern.MatchString(key)
+}
+
+// ValidateKey returns an error if key cannot be used as an environment variable name.
+func ValidateKey(key string) error {
+	if !ValidKey(key) {
+		return fmt.Errorf("invalid key %q: use letters, digits and underscores, not starting with a digit", key)
+	}
+	return nil
+}
+
 // File represents an env.toml file with multiple environments.
+// Comments, blank lines and the order of keys are kept from the file it was loaded from.
+//
+// Inherits maps an environment to the one it inherits from, as set with inherits = "name".
 type File struct {
 	Environments map[string]map[string]string
+	Inherits     map[string]string
+	layout       *layout
 }
 
 // Load reads and parses an env.toml file.
@@ -30,60 +64,113 @@ func Load(path string) (*File, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read secrets file: %w", err)
 	}
+	return Parse(data)
+}
 
-	var raw map[string]map[string]string
+// Parse parses the contents of an env.toml file. Each table is an environment, and
+// sub-tables such as [production.worker] are environments of their own. Integers, floats,
+// booleans, dates and arrays are accepted and exported as strings.
+func Parse(data []byte) (*File, error) {
+	var raw map[string]any
 	if err := toml.Unmarshal(data, &raw); err != nil {
 		return nil, fmt.Errorf("failed to parse secrets file: %w", err)
 	}
 
-	return &File{Environments: raw}, nil
-}
-
-// Save writes the secrets file to disk.
-func (f *File) Save(path string) error {
-	file, err := os.Create(path)
+	l, err := parseLayout(strings.ReplaceAll(string(data), "\r\n", "\n"))
 	if err != nil {
-		return fmt.Errorf("failed to create secrets file: %w", err)
+		return nil, fmt.Errorf("failed to parse secrets file: %w", err)
 	}
-	defer file.Close()
 
-	// Sort environment names for consistent output
-	envNames := make([]string, 0, len(f.Environments))
-	for name := range f.Environments {
-		envNames = append(envNames, name)
+	envs := make(map[string]map[string]string)
+	for _, section := range l.sections {
+		envs[section.name] = make(map[string]string)
 	}
-	sort.Strings(envNames)
-
-	// Write each environment section
-	for i, envName := range envNames {
-		if i > 0 {
-			file.WriteString("\n")
+	for name, value := range raw {
+		table, ok := value.(map[string]any)
+		if !ok {
+			return nil, fmt.Errorf("failed to parse secrets file: %s is outside of an environment section", name)
+		}
+		if err := flatten(name, table, envs); err != nil {
+			return nil, fmt.Errorf("failed to parse secrets file: %w", err)
 		}
-		fmt.Fprintf(file, "[%s]\n", envName)
+	}
 
-		env := f.Environments[envName]
-		// Sort keys for consistent output
-		keys := make([]string, 0, len(env))
-		for k := range env {
-			keys = append(keys, k)
+	f := &File{Environments: envs, Inherits: make(map[string]string), layout: l}
+	for name, env := range envs {
+		if parent, ok := env[InheritsKey]; ok {
+			f.Inherits[name] = parent
+			delete(env, InheritsKey)
 		}
-		sort.Strings(keys)
+	}
+	for name := range envs {
+		if _, err := f.Layers(name); err != nil {
+			return nil, fmt.Errorf("failed to parse secrets file: %w", err)
+		}
+	}
 
-		for _, key := range keys {
-			fmt.Fprintf(file, "%s = %q\n", key, env[key])
+	for _, section := range l.sections {
+		for _, entry := range section.entries {
+			entry.value = envs[section.name][entry.key]
+			if entry.key == InheritsKey {
+				entry.value = f.Inherits[section.name]
+			}
 		}
 	}
 
+	return f, nil
+}
+
+// Save writes the secrets file to disk.
+func (f *File) Save(path string) error {
+	if err := os.WriteFile(path, f.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write secrets file: %w", err)
+	}
 	return nil
 }
 
-// GetEnvironment returns the secrets for a specific environment.
+// Bytes returns the contents of the secrets file.
+func (f *File) Bytes() []byte {
+	return f.encode()
+}
+
+// GetEnvironment returns the secrets for a specific environment, including the values it
+// inherits. See Layers for how an environment is built up.
 func (f *File) GetEnvironment(name string) (map[string]string, error) {
-	env, ok := f.Environments[name]
+	layers, err := f.Layers(name)
+	if err != nil {
+		return nil, err
+	}
+	if len(layers) == 1 {
+		return f.Environments[name], nil
+	}
+	values, _ := Merge(layers, f.Environments)
+	return values, nil
+}
+
+// Set stores a value under key in the named environment, creating the environment if needed.
+func (f *File) Set(envName, key, value string) {
+	if f.Environments == nil {
+		f.Environments = make(map[string]map[string]string)
+	}
+	env, ok := f.Environments[envName]
+	if !ok {
+		env = make(map[string]string)
+		f.Environments[envName] = env
+	}
+	env[key] = value
+}
+
+// Unset removes key from the named environment and reports whether it was present.
+func (f *File) Unset(envName, key string) bool {
+	env, ok := f.Environments[envName]
 	if !ok {
-		return nil, fmt.Errorf("environment %q not found", name)
+		return false
+	}
+	if _, ok := env[key]; !ok {
+		return false
 	}
-	return env, nil
+	delete(env, key)
+	return true
 }
 
 // IsEncrypted checks if a value is encrypted.
@@ -91,9 +178,9 @@ func IsEncrypted(value string) bool {
 	return strings.HasPrefix(value, EncryptedPrefix) && strings.HasSuffix(value, EncryptedSuffix)
 }
 
-// EncryptValue encrypts a plaintext value.
-func EncryptValue(plaintext string, recipient age.Recipient) (string, error) {
-	ciphertext, err := ageutil.Encrypt([]byte(plaintext), recipient)
+// EncryptValue encrypts a plaintext value to all of the given recipients.
+func EncryptValue(plaintext string, recipients ...age.Recipient) (string, error) {
+	ciphertext, err := ageutil.Encrypt([]byte(plaintext), recipients...)
 	if err != nil {
 		return "", err
 	}
@@ -101,8 +188,8 @@ func EncryptValue(plaintext string, recipient age.Recipient) (string, error) {
 	return EncryptedPrefix + encoded + EncryptedSuffix, nil
 }
 
-// DecryptValue decrypts an encrypted value.
-func DecryptValue(encrypted string, identity age.Identity) (string, error) {
+// DecryptValue decrypts an encrypted value with whichever of the given identities matches.
+func DecryptValue(encrypted string, identities ...age.Identity) (string, error) {
 	if !IsEncrypted(encrypted) {
 		return encrypted, nil // Return as-is if not encrypted
 	}
@@ -115,7 +202,7 @@ func DecryptValue(encrypted string, identity age.Identity) (string, error) {
 		return "", fmt.Errorf("failed to decode encrypted value: %w", err)
 	}
 
-	plaintext, err := ageutil.Decrypt(ciphertext, identity)
+	plaintext, err := ageutil.Decrypt(ciphertext, identities...)
 	if err != nil {
 		return "", err
 	}
@@ -124,10 +211,10 @@ func DecryptValue(encrypted string, identity age.Identity) (string, error) {
 }
 
 // DecryptEnvironment decrypts all values in an environment.
-func DecryptEnvironment(env map[string]string, identity age.Identity) (map[string]string, error) {
+func DecryptEnvironment(env map[string]string, identities ...age.Identity) (map[string]string, error) {
 	result := make(map[string]string)
 	for key, value := range env {
-		decrypted, err := DecryptValue(value, identity)
+		decrypted, err := DecryptValue(value, identities...)
 		if err != nil {
 			return nil, fmt.Errorf("failed to decrypt %s: %w", key, err)
 		}
@@ -136,14 +223,41 @@ func DecryptEnvironment(env map[string]string, identity age.Identity) (map[strin
 	return result, nil
 }
 
+// DecryptAll decrypts every environment in the file. Environments that the identities
+// are not a recipient of are skipped and their names returned in sorted order, so that
+// callers can still work with the sections they do have access to.
+func (f *File) DecryptAll(identities ...age.Identity) (map[string]map[string]string, []string, error) {
+	decrypted := make(map[string]map[string]string)
+	var skipped []string
+	for envName, env := range f.Environments {
+		dec, err := DecryptEnvironment(env, identities...)
+		if IsNoIdentityMatch(err) {
+			skipped = append(skipped, envName)
+			continue
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to decrypt %s: %w", envName, err)
+		}
+		decrypted[envName] = dec
+	}
+	sort.Strings(skipped)
+	return decrypted, skipped, nil
+}
+
+// IsNoIdentityMatch reports whether err was caused by a value that is not encrypted to the identity.
+func IsNoIdentityMatch(err error) bool {
+	var noMatch *age.NoIdentityMatchError
+	return errors.As(err, &noMatch)
+}
+
 // EncryptEnvironment encrypts all plaintext values in an environment.
-func EncryptEnvironment(env map[string]string, recipient age.Recipient) (map[string]string, error) {
+func EncryptEnvironment(env map[string]string, recipients ...age.Recipient) (map[string]string, error) {
 	result := make(map[string]string)
 	for key, value := range env {
 		if IsEncrypted(value) {
 			result[key] = value // Already encrypted
 		} else {
-			encrypted, err := EncryptValue(value, recipient)
+			encrypted, err := EncryptValue(value, recipients...)
 			if err != nil {
 				return nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
 			}
@@ -153,6 +267,54 @@ func EncryptEnvironment(env map[string]string, recipient age.Recipient) (map[str
 	return result, nil
 }
 
+// ReencryptEnvironment encrypts an edited environment, reusing the existing ciphertext
+// for every value that is unchanged from its previously decrypted value. This keeps
+// diffs of env.toml limited to the keys that actually changed.
+func ReencryptEnvironment(edited, decrypted, encrypted map[string]string, recipients ...age.Recipient) (map[string]string, error) {
+	result := make(map[string]string)
+	for key, value := range edited {
+		if previous, ok := decrypted[key]; ok && previous == value {
+			if existing := encrypted[key]; IsEncrypted(existing) {
+				result[key] = existing // Unchanged, keep the original ciphertext
+				continue
+			}
+		}
+
+		if IsEncrypted(value) {
+			result[key] = value // Already encrypted
+			continue
+		}
+
+		enc, err := EncryptValue(value, recipients...)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt %s: %w", key, err)
+		}
+		result[key] = enc
+	}
+	return result, nil
+}
+
+// VerifyEnvironment checks that every encrypted value decrypts with the identity to its expected plaintext.
+func VerifyEnvironment(encrypted, expected map[string]string, identity age.Identity) error {
+	if len(encrypted) != len(expected) {
+		return fmt.Errorf("expected %d values, found %d", len(expected), len(encrypted))
+	}
+	for key, want := range expected {
+		value, ok := encrypted[key]
+		if !ok {
+			return fmt.Errorf("%s is missing", key)
+		}
+		got, err := DecryptValue(value, identity)
+		if err != nil {
+			return fmt.Errorf("failed to decrypt %s: %w", key, err)
+		}
+		if got != want {
+			return fmt.Errorf("%s does not match its original value", key)
+		}
+	}
+	return nil
+}
+
 // ToEnvList converts a map of environment variables to a slice of KEY=value strings.
 func ToEnvList(env map[string]string) []string {
 	result := make([]string, 0, len(env))
@@ -162,13 +324,19 @@ func ToEnvList(env map[string]string) []string {
 	return result
 }
 
+// DefaultEnvironments are the environments that CreateDefault creates.
+var DefaultEnvironments = []string{"development", "production"}
+
 // CreateDefault creates a default env.toml with empty development and production sections.
 func CreateDefault(path string) error {
-	f := &File{
-		Environments: map[string]map[string]string{
-			"development": {},
-			"production":  {},
-		},
+	return Create(path, DefaultEnvironments...)
+}
+
+// Create creates an env.toml with an empty section for each environment.
+func Create(path string, environments ...string) error {
+	f := &File{Environments: make(map[string]map[string]string)}
+	for _, name := range environments {
+		f.Environments[name] = map[string]string{}
 	}
 	return f.Save(path)
 }
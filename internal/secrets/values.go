@@ -0,0 +1,92 @@
+package secrets
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// flatten adds the values of a TOML table to envs under name. Sub-tables become their own
+// environments named with dots, such as production.worker.
+func flatten(name string, table map[string]any, envs map[string]map[string]string) error {
+	for key, value := range table {
+		if sub, ok := value.(map[string]any); ok {
+			if err := flatten(name+"."+key, sub, envs); err != nil {
+				return err
+			}
+			continue
+		}
+
+		s, err := stringify(value)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", name, key, err)
+		}
+		if envs[name] == nil {
+			envs[name] = make(map[string]string)
+		}
+		envs[name][key] = s
+	}
+	return nil
+}
+
+// stringify converts a TOML value to the string it is exported as. Arrays are joined with commas.
+func stringify(value any) (string, error) {
+	switch v := value.(type) {
+	case string:
+		return v, nil
+	case int64:
+		return strconv.FormatInt(v, 10), nil
+	case float64:
+		return formatFloat(v), nil
+	case bool:
+		return strconv.FormatBool(v), nil
+	case time.Time:
+		return formatTime(v), nil
+	case []any:
+		parts := make([]string, 0, len(v))
+		for _, item := range v {
+			s, err := stringify(item)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, s)
+		}
+		return strings.Join(parts, ","), nil
+	case []map[string]any, map[string]any:
+		return "", fmt.Errorf("tables inside arrays are not supported")
+	}
+	return "", fmt.Errorf("unsupported value of type %T", value)
+}
+
+// formatFloat formats a float the way it is usually written, using an exponent only for
+// very large or small numbers.
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "nan"
+	case math.IsInf(v, 1):
+		return "inf"
+	case math.IsInf(v, -1):
+		return "-inf"
+	}
+	if abs := math.Abs(v); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		return strconv.FormatFloat(v, 'e', -1, 64)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatTime formats a TOML date or time, leaving out the parts that weren't written.
+// The TOML decoder marks local dates and times with these zone names.
+func formatTime(t time.Time) string {
+	switch t.Location().String() {
+	case "datetime-local":
+		return t.Format("2006-01-02T15:04:05.999999999")
+	case "date-local":
+		return t.Format("2006-01-02")
+	case "time-local":
+		return t.Format("15:04:05.999999999")
+	}
+	return t.Format(time.RFC3339Nano)
+}
@@ -0,0 +1,122 @@
+package secrets
+
+import (
+	"strings"
+	"testing"
+)
+
+const typedFile = `[production]
+PORT = 3000
+RATIO = 0.25
+DEBUG = false
+HOSTS = ["a.example.com", "b.example.com"]
+STARTED = 2024-01-02
+API_KEY = "key"
+
+[production.worker]
+PORT = 4000 # worker port
+QUEUE = "jobs"
+`
+
+func TestParseValues(t *testing.T) {
+	t.Run("stringifies non-string values", func(t *testing.T) {
+		f := parseTest(t, typedFile)
+
+		want := map[string]string{
+			"PORT":    "3000",
+			"RATIO":   "0.25",
+			"DEBUG":   "false",
+			"HOSTS":   "a.example.com,b.example.com",
+			"STARTED": "2024-01-02",
+			"API_KEY": "key",
+		}
+		for k, v := range want {
+			if got := f.Environments["production"][k]; got != v {
+				t.Errorf("%s = %q, want %q", k, got, v)
+			}
+		}
+	})
+
+	t.Run("writes non-string values back as is", func(t *testing.T) {
+		f := parseTest(t, typedFile)
+		f.Set("production", "API_KEY", "changed")
+
+		got := string(f.Bytes())
+		if want := strings.Replace(typedFile, `"key"`, `"changed"`, 1); got != want {
+			t.Errorf("Bytes() =\n%s\nwant\n%s", got, want)
+		}
+	})
+
+	t.Run("reads sub-tables as nested environments", func(t *testing.T) {
+		f := parseTest(t, typedFile)
+
+		worker, err := f.GetEnvironment("production.worker")
+		if err != nil {
+			t.Fatalf("GetEnvironment() error = %v", err)
+		}
+		if worker["PORT"] != "4000" || worker["QUEUE"] != "jobs" {
+			t.Errorf("worker = %v, want its own values", worker)
+		}
+		if worker["API_KEY"] != "key" {
+			t.Errorf("API_KEY = %q, want it inherited from production", worker["API_KEY"])
+		}
+		if _, ok := f.Environments["production"]["QUEUE"]; ok {
+			t.Error("QUEUE leaked into production")
+		}
+	})
+
+	t.Run("keeps literals plaintext after encryption", func(t *testing.T) {
+		f := parseTest(t, typedFile)
+		identity := generateTestIdentity(t)
+
+		encrypted, err := EncryptEnvironment(f.Environments["production"], identity.Recipient())
+		if err != nil {
+			t.Fatalf("EncryptEnvironment() error = %v", err)
+		}
+		f.KeepLiterals("production", encrypted)
+
+		if encrypted["PORT"] != "3000" {
+			t.Errorf("PORT = %q, want it kept plaintext", encrypted["PORT"])
+		}
+		if !IsEncrypted(encrypted["API_KEY"]) {
+			t.Errorf("API_KEY = %q, want it encrypted", encrypted["API_KEY"])
+		}
+	})
+
+	t.Run("rejects tables it cannot round-trip", func(t *testing.T) {
+		tests := map[string]string{
+			"inline table":    "[production]\nworker = { PORT = 1 }\n",
+			"table in array":  "[production]\nA = [{ B = 1 }]\n",
+			"root-level keys": "PORT = 1\n",
+		}
+		for name, data := range tests {
+			if _, err := Parse([]byte(data)); err == nil {
+				t.Errorf("%s: Parse() should have failed", name)
+			}
+		}
+	})
+}
+
+func TestStringify(t *testing.T) {
+	tests := []struct {
+		value any
+		want  string
+	}{
+		{"text", "text"},
+		{int64(-42), "-42"},
+		{1.5, "1.5"},
+		{1e6, "1000000"},
+		{1e22, "1e+22"},
+		{true, "true"},
+		{[]any{int64(1), "two", []any{false}}, "1,two,false"},
+	}
+	for _, tt := range tests {
+		got, err := stringify(tt.value)
+		if err != nil {
+			t.Errorf("stringify(%v) error = %v", tt.value, err)
+		}
+		if got != tt.want {
+			t.Errorf("stringify(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,141 @@
+package shell
+
+import (
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// Dialects lists the supported shells.
+var Dialects = []string{"posix", "fish", "pwsh", "nu", "cmd"}
+
+// Detect returns the dialect of the shell at the given path, typically $SHELL.
+// Unknown shells are assumed to be POSIX compatible; without a shell, Windows uses cmd.
+func Detect(shellPath string) string {
+	name := strings.TrimSuffix(filepath.Base(shellPath), ".exe")
+	switch {
+	case shellPath == "" && runtime.GOOS == "windows":
+		return "cmd"
+	case name == "fish":
+		return "fish"
+	case name == "pwsh" || name == "powershell":
+		return "pwsh"
+	case name == "nu":
+		return "nu"
+	default:
+		return "posix"
+	}
+}
+
+// Set returns a statement that sets the environment variable key to value in the dialect.
+func Set(dialect, key, value string) (string, error) {
+	switch dialect {
+	case "posix":
+		return fmt.Sprintf("export %s=%s", key, quotePOSIX(value)), nil
+	case "fish":
+		return fmt.Sprintf("set -gx %s %s", key, quoteFish(value)), nil
+	case "pwsh":
+		return fmt.Sprintf("$env:%s = %s", key, quotePwsh(value)), nil
+	case "nu":
+		return fmt.Sprintf("$env.%s = %s", key, quoteNu(value)), nil
+	case "cmd":
+		if strings.ContainsAny(value, "\r\n") {
+			return "", fmt.Errorf("%s has a line break, which cmd variables cannot hold", key)
+		}
+		return fmt.Sprintf("set %s=%s", key, escapeCmd(value)), nil
+	default:
+		return "", unknown(dialect)
+	}
+}
+
+// Unset returns a statement that removes the environment variable key in the dialect.
+func Unset(dialect, key string) (string, error) {
+	switch dialect {
+	case "posix":
+		return "unset " + key, nil
+	case "fish":
+		return "set -e " + key, nil
+	case "pwsh":
+		return fmt.Sprintf("Remove-Item Env:%s -ErrorAction SilentlyContinue", key), nil
+	case "nu":
+		return "hide-env -i " + key, nil
+	case "cmd":
+		return fmt.Sprintf("set %s=", key), nil
+	default:
+		return "", unknown(dialect)
+	}
+}
+
+func unknown(dialect string) error {
+	return fmt.Errorf("unknown shell %q (use %s)", dialect, strings.Join(Dialects, ", "))
+}
+
+// quotePOSIX single-quotes a value, which prevents all expansion. Embedded single quotes
+// end the quoted string, are added in double quotes, and start a new one.
+func quotePOSIX(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'"'"'`) + "'"
+}
+
+// quoteFish single-quotes a value; fish only treats \\ and \' as escapes inside them.
+func quoteFish(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	return "'" + strings.ReplaceAll(value, "'", `\'`) + "'"
+}
+
+// quotePwsh single-quotes a value. PowerShell also treats typographic single quotes as
+// quotes, so every kind is doubled.
+func quotePwsh(value string) string {
+	var b strings.Builder
+	b.WriteByte('\'')
+	for _, r := range value {
+		switch r {
+		case '\'', '\u2018', '\u2019', '\u201a', '\u201b':
+			b.WriteRune(r)
+		}
+		b.WriteRune(r)
+	}
+	b.WriteByte('\'')
+	return b.String()
+}
+
+// quoteNu double-quotes a value with nushell's escapes.
+func quoteNu(value string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range value {
+		switch {
+		case r == '"' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\n':
+			b.WriteString(`\n`)
+		case r == '\r':
+			b.WriteString(`\r`)
+		case r == '\t':
+			b.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&b, `\u{%x}`, r)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// escapeCmd escapes a value for an unquoted set statement in a batch file. Carets escape
+// cmd's special characters, including quotes, and % is doubled.
+func escapeCmd(value string) string {
+	var b strings.Builder
+	for _, r := range value {
+		switch r {
+		case '^', '&', '|', '<', '>', '(', ')', '"':
+			b.WriteByte('^')
+		case '%':
+			b.WriteByte('%')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,108 @@
+package shell
+
+import (
+	"os/exec"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := map[string]string{
+		"/bin/bash":             "posix",
+		"/usr/bin/zsh":          "posix",
+		"/usr/local/bin/fish":   "fish",
+		"/usr/bin/pwsh":         "pwsh",
+		"C:\\pwsh\\pwsh.exe":    "pwsh",
+		"/opt/homebrew/bin/nu":  "nu",
+		"/usr/bin/unknownshell": "posix",
+	}
+	for path, want := range tests {
+		if runtime.GOOS != "windows" && strings.Contains(path, "\\") {
+			continue
+		}
+		if got := Detect(path); got != want {
+			t.Errorf("Detect(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
+func TestSet(t *testing.T) {
+	value := "it's \"$HOME\" `x` \\n\nline two"
+
+	t.Run("quotes each dialect", func(t *testing.T) {
+		tests := map[string]string{
+			"posix": `export KEY='it'"'"'s "$HOME" ` + "`x`" + ` \n` + "\n" + `line two'`,
+			"fish":  `set -gx KEY 'it\'s "$HOME" ` + "`x`" + ` \\n` + "\n" + `line two'`,
+			"pwsh":  `$env:KEY = 'it''s "$HOME" ` + "`x`" + ` \n` + "\n" + `line two'`,
+			"nu":    `$env.KEY = "it's \"$HOME\" ` + "`x`" + ` \\n\nline two"`,
+		}
+		for dialect, want := range tests {
+			got, err := Set(dialect, "KEY", value)
+			if err != nil {
+				t.Fatalf("Set(%s) error = %v", dialect, err)
+			}
+			if got != want {
+				t.Errorf("Set(%s) =\n%s\nwant\n%s", dialect, got, want)
+			}
+		}
+	})
+
+	t.Run("escapes cmd special characters", func(t *testing.T) {
+		got, err := Set("cmd", "KEY", `a&b|c>"d" 100% ^`)
+		if err != nil {
+			t.Fatalf("Set(cmd) error = %v", err)
+		}
+		if want := `set KEY=a^&b^|c^>^"d^" 100%% ^^`; got != want {
+			t.Errorf("Set(cmd) = %s, want %s", got, want)
+		}
+		if _, err := Set("cmd", "KEY", "two\nlines"); err == nil {
+			t.Error("Set(cmd) should reject line breaks")
+		}
+	})
+
+	t.Run("doubles typographic quotes for pwsh", func(t *testing.T) {
+		got, _ := Set("pwsh", "KEY", "it\u2019s")
+		if want := "$env:KEY = 'it\u2019\u2019s'"; got != want {
+			t.Errorf("Set(pwsh) = %s, want %s", got, want)
+		}
+	})
+
+	t.Run("round-trips through sh", func(t *testing.T) {
+		if _, err := exec.LookPath("sh"); err != nil {
+			t.Skip("sh not available")
+		}
+		stmt, _ := Set("posix", "KEY", value)
+		out, err := exec.Command("sh", "-c", stmt+"\nprintf '%s' \"$KEY\"").Output()
+		if err != nil {
+			t.Fatalf("sh error = %v", err)
+		}
+		if string(out) != value {
+			t.Errorf("sh printed %q, want %q", out, value)
+		}
+	})
+
+	t.Run("rejects unknown dialects", func(t *testing.T) {
+		if _, err := Set("tcsh", "KEY", "x"); err == nil {
+			t.Error("Set(tcsh) should have failed")
+		}
+		if _, err := Unset("tcsh", "KEY"); err == nil {
+			t.Error("Unset(tcsh) should have failed")
+		}
+	})
+}
+
+func TestUnset(t *testing.T) {
+	tests := map[string]string{
+		"posix": "unset KEY",
+		"fish":  "set -e KEY",
+		"pwsh":  "Remove-Item Env:KEY -ErrorAction SilentlyContinue",
+		"nu":    "hide-env -i KEY",
+		"cmd":   "set KEY=",
+	}
+	for dialect, want := range tests {
+		if got, _ := Unset(dialect, "KEY"); got != want {
+			t.Errorf("Unset(%s) = %q, want %q", dialect, got, want)
+		}
+	}
+}
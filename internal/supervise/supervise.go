@@ -0,0 +1,193 @@
+package supervise
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// Forwarded lists the signals that are passed on to the child process.
+var Forwarded = []os.Signal{syscall.SIGINT, syscall.SIGTERM, syscall.SIGHUP}
+
+// DefaultGrace is how long a child is given to exit after it is asked to stop.
+const DefaultGrace = 10 * time.Second
+
+// Status describes how a child process exited.
+type Status struct {
+	// Code is the exit code, or -1 if the child was killed by a signal.
+	Code int
+	// Signal is the signal that killed the child, if any.
+	Signal syscall.Signal
+	// TimedOut is set if the child was stopped because it ran too long.
+	TimedOut bool
+}
+
+// Process is a running child process.
+type Process struct {
+	cmd    *exec.Cmd
+	done   chan struct{}
+	status Status
+	err    error
+}
+
+// Start starts cmd in a process group of its own, so that stopping it also stops anything it
+// started, and waits for it to exit in the background. If cmd reads from the terminal that
+// this process is in the foreground of, the child's group takes over the terminal until the
+// child exits.
+func Start(cmd *exec.Cmd) (*Process, error) {
+	if cmd.SysProcAttr == nil {
+		cmd.SysProcAttr = &syscall.SysProcAttr{}
+	}
+	cmd.SysProcAttr.Setpgid = true
+	tty := foregroundTerminal(cmd.Stdin)
+	if tty != nil {
+		cmd.SysProcAttr.Foreground = true
+		cmd.SysProcAttr.Ctty = int(tty.Fd())
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start %s: %w", cmd.Path, err)
+	}
+
+	p := &Process{cmd: cmd, done: make(chan struct{})}
+	go func() {
+		err := cmd.Wait()
+		if tty != nil {
+			takeTerminal(tty)
+		}
+		var exitErr *exec.ExitError
+		if err != nil && !errors.As(err, &exitErr) {
+			p.err = fmt.Errorf("failed to wait for %s: %w", cmd.Path, err)
+		}
+		p.status = statusOf(cmd.ProcessState)
+		close(p.done)
+	}()
+	return p, nil
+}
+
+// foregroundTerminal returns stdin if it is a terminal and this process is in its foreground
+// process group, or nil otherwise.
+func foregroundTerminal(stdin io.Reader) *os.File {
+	f, ok := stdin.(*os.File)
+	if !ok {
+		return nil
+	}
+	pgrp, err := unix.IoctlGetInt(int(f.Fd()), unix.TIOCGPGRP)
+	if err != nil || pgrp != unix.Getpgrp() {
+		return nil
+	}
+	return f
+}
+
+// takeTerminal moves this process's group back into the foreground of tty.
+func takeTerminal(tty *os.File) {
+	// Changing the foreground group from the background raises SIGTTOU unless it is ignored
+	signal.Ignore(syscall.SIGTTOU)
+	defer signal.Reset(syscall.SIGTTOU)
+	unix.IoctlSetPointerInt(int(tty.Fd()), unix.TIOCSPGRP, unix.Getpgrp())
+}
+
+// statusOf returns the exit code or signal of a finished process.
+func statusOf(state *os.ProcessState) Status {
+	if state == nil {
+		return Status{Code: -1}
+	}
+	s := Status{Code: state.ExitCode()}
+	if ws, ok := state.Sys().(syscall.WaitStatus); ok && ws.Signaled() {
+		s.Signal = ws.Signal()
+	}
+	return s
+}
+
+// Done is closed when the process has exited.
+func (p *Process) Done() <-chan struct{} {
+	return p.done
+}
+
+// Wait waits for the process to exit and returns how it exited.
+func (p *Process) Wait() (Status, error) {
+	<-p.done
+	return p.status, p.err
+}
+
+// Signal sends a signal to the process, unless it has already exited.
+func (p *Process) Signal(sig os.Signal) {
+	select {
+	case <-p.done:
+	default:
+		p.cmd.Process.Signal(sig)
+	}
+}
+
+// signalGroup sends a signal to the process and everything else in its process group.
+func (p *Process) signalGroup(sig syscall.Signal) {
+	syscall.Kill(-p.cmd.Process.Pid, sig)
+}
+
+// Stop sends sig to the process group and kills the group if the process has not exited
+// after grace.
+func (p *Process) Stop(sig syscall.Signal, grace time.Duration) (Status, error) {
+	p.signalGroup(sig)
+	select {
+	case <-p.done:
+	case <-time.After(grace):
+		p.signalGroup(syscall.SIGKILL)
+	}
+	return p.Wait()
+}
+
+// Run runs cmd, passing the Forwarded signals on to it, and returns how it exited. If timeout
+// is not zero, the child's process group is sent the stop signal once it has run that long, and
+// killed after grace.
+func Run(cmd *exec.Cmd, timeout time.Duration, stop syscall.Signal, grace time.Duration) (Status, error) {
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, Forwarded...)
+	defer signal.Stop(signals)
+
+	p, err := Start(cmd)
+	if err != nil {
+		return Status{}, err
+	}
+
+	var deadline <-chan time.Time
+	if timeout > 0 {
+		timer := time.NewTimer(timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+
+	for {
+		select {
+		case sig := <-signals:
+			p.Signal(sig)
+		case <-deadline:
+			status, err := p.Stop(stop, grace)
+			status.TimedOut = true
+			return status, err
+		case <-p.Done():
+			return p.Wait()
+		}
+	}
+}
+
+// Exit ends the current process the way the child exited: with the same exit code, or
+// by the same signal. A child that timed out exits with 124, like timeout(1).
+func Exit(s Status) {
+	if s.TimedOut {
+		os.Exit(124)
+	}
+	if s.Signal != 0 {
+		signal.Reset(s.Signal)
+		syscall.Kill(os.Getpid(), s.Signal)
+		// Not reached unless the signal is ignored
+		os.Exit(128 + int(s.Signal))
+	}
+	os.Exit(s.Code)
+}
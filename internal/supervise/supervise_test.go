@@ -0,0 +1,136 @@
+package supervise
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestRun(t *testing.T) {
+	t.Run("returns the exit code", func(t *testing.T) {
+		status, err := Run(exec.Command("sh", "-c", "exit 3"), 0, syscall.SIGTERM, DefaultGrace)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if status.Code != 3 || status.Signal != 0 || status.TimedOut {
+			t.Errorf("Run() = %+v, want exit code 3", status)
+		}
+	})
+
+	t.Run("returns the signal that killed the child", func(t *testing.T) {
+		status, err := Run(exec.Command("sh", "-c", "kill -TERM $$"), 0, syscall.SIGTERM, DefaultGrace)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if status.Signal != syscall.SIGTERM || status.Code != -1 {
+			t.Errorf("Run() = %+v, want SIGTERM", status)
+		}
+	})
+
+	t.Run("forwards signals to the child", func(t *testing.T) {
+		go func() {
+			time.Sleep(300 * time.Millisecond)
+			syscall.Kill(os.Getpid(), syscall.SIGHUP)
+		}()
+
+		status, err := Run(exec.Command("sh", "-c", `trap "exit 7" HUP; sleep 5 & wait`), 0, syscall.SIGTERM, DefaultGrace)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if status.Code != 7 {
+			t.Errorf("Run() = %+v, want exit code 7 from the HUP trap", status)
+		}
+	})
+
+	t.Run("stops the child after the timeout", func(t *testing.T) {
+		start := time.Now()
+		status, err := Run(exec.Command("sleep", "5"), 100*time.Millisecond, syscall.SIGTERM, time.Second)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if !status.TimedOut || status.Signal != syscall.SIGTERM {
+			t.Errorf("Run() = %+v, want a timeout", status)
+		}
+		if elapsed := time.Since(start); elapsed > 2*time.Second {
+			t.Errorf("Run() took %v", elapsed)
+		}
+	})
+
+	t.Run("stops the child's own children after the timeout", func(t *testing.T) {
+		cmd, exited := withGrandchild(t)
+		status, err := Run(cmd, 100*time.Millisecond, syscall.SIGTERM, time.Second)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if !status.TimedOut {
+			t.Errorf("Run() = %+v, want a timeout", status)
+		}
+		exited()
+	})
+
+	t.Run("kills a child that ignores the stop signal", func(t *testing.T) {
+		status, err := Run(exec.Command("sh", "-c", `trap "" TERM; sleep 5 & wait; sleep 5`), 100*time.Millisecond, syscall.SIGTERM, 200*time.Millisecond)
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		if !status.TimedOut || status.Signal != syscall.SIGKILL {
+			t.Errorf("Run() = %+v, want SIGKILL", status)
+		}
+	})
+
+	t.Run("fails for a missing command", func(t *testing.T) {
+		if _, err := Run(exec.Command("/nonexistent/command"), 0, syscall.SIGTERM, DefaultGrace); err == nil {
+			t.Error("Run() should have failed")
+		}
+	})
+}
+
+func TestStop(t *testing.T) {
+	t.Run("stops the child's own children", func(t *testing.T) {
+		cmd, exited := withGrandchild(t)
+		p, err := Start(cmd)
+		if err != nil {
+			t.Fatalf("Start() error = %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+
+		status, err := p.Stop(syscall.SIGTERM, time.Second)
+		if err != nil {
+			t.Fatalf("Stop() error = %v", err)
+		}
+		if status.Signal != syscall.SIGTERM {
+			t.Errorf("Stop() = %+v, want SIGTERM", status)
+		}
+		exited()
+	})
+}
+
+// withGrandchild returns a command that starts a long-running child of its own, and a function
+// that fails the test unless that grandchild has exited. The grandchild holds the write end of
+// a pipe, so it has exited once the read end sees EOF.
+func withGrandchild(t *testing.T) (*exec.Cmd, func()) {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { r.Close() })
+
+	cmd := exec.Command("sh", "-c", "sleep 30 & wait")
+	cmd.ExtraFiles = []*os.File{w}
+	return cmd, func() {
+		w.Close()
+		eof := make(chan struct{})
+		go func() {
+			io.Copy(io.Discard, r)
+			close(eof)
+		}()
+		select {
+		case <-eof:
+		case <-time.After(2 * time.Second):
+			t.Error("grandchild is still running")
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package supervise
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// signals maps the names accepted by ParseSignal to signals.
+var signals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+// ParseSignal parses a signal name such as TERM or SIGINT.
+func ParseSignal(name string) (syscall.Signal, error) {
+	sig, ok := signals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		return 0, fmt.Errorf("unknown signal %q (use HUP, INT, QUIT, KILL, USR1, USR2 or TERM)", name)
+	}
+	return sig, nil
+}
+
+type fileState struct {
+	exists  bool
+	size    int64
+	modTime time.Time
+}
+
+// Poller notices changes to a set of files by comparing their size and modification time.
+// A file that is created or removed counts as changed.
+type Poller struct {
+	paths []string
+	state map[string]fileState
+}
+
+// NewPoller returns a Poller for the given paths, recording their current state.
+func NewPoller(paths ...string) *Poller {
+	p := &Poller{paths: paths, state: make(map[string]fileState)}
+	p.Changed()
+	return p
+}
+
+// Changed reports whether any of the files changed since the last call.
+func (p *Poller) Changed() bool {
+	changed := false
+	for _, path := range p.paths {
+		var s fileState
+		if info, err := os.Stat(path); err == nil {
+			s = fileState{exists: true, size: info.Size(), modTime: info.ModTime()}
+		}
+		if s != p.state[path] {
+			changed = true
+			p.state[path] = s
+		}
+	}
+	return changed
+}
@@ -0,0 +1,62 @@
+package supervise
+
+import (
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestParseSignal(t *testing.T) {
+	tests := map[string]syscall.Signal{
+		"TERM":    syscall.SIGTERM,
+		"SIGINT":  syscall.SIGINT,
+		"hup":     syscall.SIGHUP,
+		"sigusr2": syscall.SIGUSR2,
+	}
+	for name, want := range tests {
+		got, err := ParseSignal(name)
+		if err != nil || got != want {
+			t.Errorf("ParseSignal(%q) = %v, %v, want %v", name, got, err, want)
+		}
+	}
+	if _, err := ParseSignal("SIGNOPE"); err == nil {
+		t.Error("ParseSignal(SIGNOPE) should have failed")
+	}
+}
+
+func TestPoller(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "env.toml")
+	missing := filepath.Join(dir, "env.local.toml")
+	os.WriteFile(path, []byte("a"), 0644)
+
+	p := NewPoller(path, missing)
+	if p.Changed() {
+		t.Error("Changed() = true without any change")
+	}
+
+	os.WriteFile(path, []byte("ab"), 0644)
+	if !p.Changed() {
+		t.Error("Changed() = false after a write")
+	}
+	if p.Changed() {
+		t.Error("Changed() = true twice for the same write")
+	}
+
+	later := time.Now().Add(time.Minute)
+	os.Chtimes(path, later, later)
+	if !p.Changed() {
+		t.Error("Changed() = false after the modification time changed")
+	}
+
+	os.WriteFile(missing, nil, 0644)
+	if !p.Changed() {
+		t.Error("Changed() = false after a file was created")
+	}
+	os.Remove(missing)
+	if !p.Changed() {
+		t.Error("Changed() = false after a file was removed")
+	}
+}